@@ -1,37 +1,173 @@
 package command
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"jit/internal"
+	"os"
+	"strings"
 )
 
+// Add stages new and modified files and the deletion of missing files
+// below <paths>, directories and "." included
 func Add(paths []string) error {
-	patterns, err := internal.LoadIgnorePatterns()
-	if err != nil {
-		return fmt.Errorf("failed to load .jitignore: %w", err)
-	}
-
 	if len(paths) < 1 {
-		return fmt.Errorf("%sNo file specified.%s\nUsage: jit add <file1> <file2> ...",
+		return fmt.Errorf("%sNo file specified.%s\nUsage: jit add [-A | -u] <file1> <file2> ...",
 			colorRed, colorNone,
 		)
 	}
+	return stage(paths, false)
+}
 
+// AddAll stages every change below <paths>, the whole working tree
+// when no paths are given
+func AddAll(paths []string) error {
+	return stage(paths, false)
+}
+
+// AddUpdate stages modifications and deletions of tracked files below
+// <paths>, the whole working tree when no paths are given
+func AddUpdate(paths []string) error {
+	return stage(paths, true)
+}
+
+func stage(paths []string, trackedOnly bool) error {
+	patterns, err := internal.LoadIgnorePatterns()
+	if err != nil {
+		return fmt.Errorf("failed to load .jitignore: %w", err)
+	}
+
+	var selected []string
 	for _, path := range paths {
-		if path == "." {
-			return fmt.Errorf("%sNo file specified.%s\nUsage: jit add <file1> <file2> ...",
-				colorRed, colorNone,
-			)
-		}
-		if internal.IsIgnonored(path, patterns) {
+		if path != "." && internal.IsIgnonored(path, patterns) {
 			fmt.Printf("Skipping ingored file: %s\n", path)
 			continue
 		}
-		if err := internal.AddToIndex(path); err != nil {
-			return err
+		selected = append(selected, path)
+	}
+	if len(paths) > 0 && len(selected) == 0 {
+		return nil
+	}
+
+	changes, err := internal.StageFiles(selected, trackedOnly)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if change.Change == internal.StatusDeleted {
+			fmt.Printf("Removed '%s' from staging area.\n", change.Path)
+		} else {
+			fmt.Printf("Added '%s' to staging area.\n", change.Path)
 		}
-		fmt.Printf("Added '%s' to staging area.\n", path)
 	}
+	return nil
+}
+
+const hunkHelp = `y - stage this hunk
+n - do not stage this hunk
+q - quit, do not stage this hunk or any of the remaining ones
+s - split the current hunk into smaller hunks
+e - manually edit the current hunk
+? - print help
+`
+
+const hunkEditHelp = `# Manual hunk edit mode
+# To remove '-' lines, make them ' ' lines (context).
+# To remove '+' lines, delete them.
+# Lines starting with # will be removed.
+`
+
+// AddPatch asks hunk by hunk which changes of the tracked files
+// below <paths> to stage
+func AddPatch(paths []string) error {
+	return addPatch(os.Stdin, os.Stdout, paths)
+}
+
+func addPatch(in io.Reader, out io.Writer, paths []string) error {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := internal.PatchFiles(paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(out, "No changes.")
+		return nil
+	}
+
+	answers := bufio.NewScanner(in)
+	for _, file := range files {
+		patch, err := internal.DiffWorkingFile(file)
+		if err != nil {
+			return err
+		}
+		if len(patch.Hunks) == 0 {
+			// only the mode changed
+			continue
+		}
+		fmt.Fprintf(out, "%sdiff '%s'%s\n", colorGreen, patch.Path, colorNone)
+
+		var staged []internal.Hunk
+		quit := false
+		hunks := patch.Hunks
+		for i := 0; i < len(hunks) && !quit; i++ {
+			hunk := hunks[i]
+			fmt.Fprint(out, hunk.String())
+
+			choices := "y,n,q,e,?"
+			if hunk.CanSplit() {
+				choices = "y,n,q,s,e,?"
+			}
+			fmt.Fprintf(out, "(%d/%d) Stage this hunk [%s]? ", i+1, len(hunks), choices)
+			if !answers.Scan() {
+				quit = true
+				break
+			}
 
+			switch strings.TrimSpace(answers.Text()) {
+			case "y":
+				staged = append(staged, hunk)
+			case "n":
+			case "q":
+				quit = true
+			case "s":
+				if !hunk.CanSplit() {
+					fmt.Fprintln(out, "Sorry, cannot split this hunk")
+					i--
+					break
+				}
+				parts := hunk.Split()
+				fmt.Fprintf(out, "Split into %d hunks.\n", len(parts))
+				hunks = append(hunks[:i:i], append(parts, hunks[i+1:]...)...)
+				i--
+			case "e":
+				edited, err := editText("ADD_EDIT.hunk", hunkEditHelp+hunk.String())
+				if err == nil {
+					hunk, err = internal.ParseHunk(hunk, edited)
+				}
+				if err != nil {
+					fmt.Fprintf(out, "%s%v%s\n", colorRed, err, colorNone)
+					i--
+					break
+				}
+				staged = append(staged, hunk)
+			default:
+				fmt.Fprint(out, hunkHelp)
+				i--
+			}
+		}
+
+		if len(staged) > 0 {
+			if err := internal.StageHunks(patch, staged); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Staged %d hunk(s) of '%s'.\n", len(staged), patch.Path)
+		}
+		if quit {
+			break
+		}
+	}
 	return nil
 }
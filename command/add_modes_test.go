@@ -0,0 +1,102 @@
+package command
+
+import (
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAddModes(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	files := map[string]string{
+		".jitignore":          "ignored.txt\n",
+		"top.txt":             "top\n",
+		"ignored.txt":         "ignored\n",
+		"dir/nested/deep.txt": "deep\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+	}
+
+	indexPaths := func() []string {
+		index, err := internal.ReadIndex(".")
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		var paths []string
+		for _, entry := range *index {
+			paths = append(paths, entry.Filepath)
+		}
+		return paths
+	}
+
+	// testing
+	t.Run("Add . stages nested files and respects .jitignore", func(t *testing.T) {
+		if err := Add([]string{"."}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		expected := []string{"dir/nested/deep.txt", "top.txt"}
+		if got := indexPaths(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected index %v, got %v", expected, got)
+		}
+		if err := Commit("first"); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	})
+
+	t.Run("Add -u skips untracked files", func(t *testing.T) {
+		if err := os.WriteFile("top.txt", []byte("changed\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := os.WriteFile("untracked.txt", []byte("untracked\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := AddUpdate(nil); err != nil {
+			t.Fatalf("AddUpdate failed: %v", err)
+		}
+
+		status, err := internal.GetStatus()
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		expected := []internal.FileChange{{Path: "top.txt", Change: internal.StatusModified}}
+		if !reflect.DeepEqual(status.Staged, expected) {
+			t.Errorf("Expected staged changes %v, got %v", expected, status.Staged)
+		}
+		if !reflect.DeepEqual(status.Untracked, []string{"untracked.txt"}) {
+			t.Errorf("Expected untracked.txt to stay untracked, got %v", status.Untracked)
+		}
+	})
+
+	t.Run("Add -A stages deletions", func(t *testing.T) {
+		if err := os.RemoveAll("dir"); err != nil {
+			t.Fatalf("Failed to remove test directory: %v", err)
+		}
+		if err := AddAll(nil); err != nil {
+			t.Fatalf("AddAll failed: %v", err)
+		}
+		expected := []string{"top.txt", "untracked.txt"}
+		if got := indexPaths(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected index %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Unmatched paths fail", func(t *testing.T) {
+		if err := Add([]string{"missing.txt"}); err == nil {
+			t.Errorf("Expected adding a missing file to fail")
+		}
+	})
+}
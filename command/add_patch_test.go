@@ -0,0 +1,125 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"jit/internal"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAddPatch(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	var lines []string
+	for i := 1; i <= 12; i++ {
+		lines = append(lines, fmt.Sprintf("line %d\n", i))
+	}
+	original := strings.Join(lines, "")
+	if err := os.WriteFile("file.txt", []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := Add([]string{"file.txt"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// changes lines <changed> of the working file
+	// and returns the content with only <staged> changed
+	edit := func(changed []int, staged []int) string {
+		working := append([]string{}, lines...)
+		expected := append([]string{}, lines...)
+		for _, n := range changed {
+			working[n-1] = fmt.Sprintf("changed %d\n", n)
+		}
+		for _, n := range staged {
+			expected[n-1] = fmt.Sprintf("changed %d\n", n)
+		}
+		if err := os.WriteFile("file.txt", []byte(strings.Join(working, "")), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		return strings.Join(expected, "")
+	}
+	stagedContent := func() string {
+		index, err := internal.ReadIndex(".")
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		return readBlob(t, (*index)[0].Hash)
+	}
+	reset := func() {
+		if err := os.WriteFile("file.txt", []byte(original), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// testing
+	t.Run("Only chosen hunks are staged", func(t *testing.T) {
+		defer reset()
+		expected := edit([]int{1, 12}, []int{1})
+		if err := addPatch(strings.NewReader("y\nn\n"), io.Discard, []string{"file.txt"}); err != nil {
+			t.Fatalf("addPatch failed: %v", err)
+		}
+		if got := stagedContent(); got != expected {
+			t.Errorf("Expected staged content:\n%s\nGot:\n%s", expected, got)
+		}
+		data, err := os.ReadFile("file.txt")
+		if err != nil || !strings.Contains(string(data), "changed 12") {
+			t.Errorf("Expected the working file to keep its changes")
+		}
+	})
+
+	t.Run("Split hunks are staged separately", func(t *testing.T) {
+		defer reset()
+		expected := edit([]int{3, 7}, []int{7})
+		if err := addPatch(strings.NewReader("s\nn\ny\n"), io.Discard, []string{"."}); err != nil {
+			t.Fatalf("addPatch failed: %v", err)
+		}
+		if got := stagedContent(); got != expected {
+			t.Errorf("Expected staged content:\n%s\nGot:\n%s", expected, got)
+		}
+	})
+
+	t.Run("Edited hunks are staged as edited", func(t *testing.T) {
+		defer reset()
+		edit([]int{5}, nil)
+		patch, err := internal.DiffWorkingFile("file.txt")
+		if err != nil {
+			t.Fatalf("DiffWorkingFile failed: %v", err)
+		}
+		text := strings.Replace(patch.Hunks[0].String(), "+changed 5", "+edited 5", 1)
+		hunk, err := internal.ParseHunk(patch.Hunks[0], text)
+		if err != nil {
+			t.Fatalf("ParseHunk failed: %v", err)
+		}
+		if err := internal.StageHunks(patch, []internal.Hunk{hunk}); err != nil {
+			t.Fatalf("StageHunks failed: %v", err)
+		}
+		expected := strings.Replace(original, "line 5\n", "edited 5\n", 1)
+		if got := stagedContent(); got != expected {
+			t.Errorf("Expected staged content:\n%s\nGot:\n%s", expected, got)
+		}
+
+		broken := strings.Replace(patch.Hunks[0].String(), " line 4", " line four", 1)
+		if _, err := internal.ParseHunk(patch.Hunks[0], broken); err == nil {
+			t.Errorf("Expected an edit of staged lines to be rejected")
+		}
+	})
+}
+
+// readBlob returns the content of the loose blob with <hash>
+func readBlob(t *testing.T, hash string) string {
+	_, data, err := internal.NewLooseObjectStore(".").Get(hash)
+	if err != nil {
+		t.Fatalf("Failed to read blob %s: %v", hash, err)
+	}
+	return string(data)
+}
@@ -1,9 +1,13 @@
 package command
 
 import (
+	"bytes"
+	"compress/zlib"
 	"crypto/sha1"
 	"fmt"
+	"io"
 	"jit/config"
+	"jit/internal"
 	"os"
 	"path/filepath"
 	"testing"
@@ -29,26 +33,40 @@ func TestAdd(t *testing.T) {
 		t.Fatalf("Add failed: %v", err)
 	}
 
-	expectedHash := computeHash(testFileContent)
+	expectedHash := computeHash([]byte(
+		fmt.Sprintf("blob %d\x00%s", len(testFileContent), testFileContent)))
 
 	objectFilePath := filepath.Join(
-		config.REPO_DIR, config.OBJECTS_DIR, expectedHash)
+		config.REPO_DIR, config.OBJECTS_DIR, expectedHash[:2], expectedHash[2:])
 	if _, err := os.Stat(objectFilePath); os.IsNotExist(err) {
 		t.Errorf("Expected object file does not exist: %s", objectFilePath)
 	}
 
-	indexFilePath := filepath.Join(config.REPO_DIR, "index")
-	indexData, err := os.ReadFile(indexFilePath)
+	object, err := readObjectFile(objectFilePath)
+	if err != nil {
+		t.Fatalf("Failed to read object file: %v", err)
+	}
+	expectedObject := fmt.Sprintf("blob %d\x00%s", len(testFileContent), testFileContent)
+	if string(object) != expectedObject {
+		t.Errorf("Object content mismatch.\nExpected: %q\nGot: %q",
+			expectedObject, string(object),
+		)
+	}
+
+	index, err := internal.ReadIndex(".")
 	if err != nil {
 		t.Fatalf("Failed to read index file: %v", err)
 	}
 
-	expectedIndexEntry := fmt.Sprintf("%s %s\n", expectedHash, testFileName)
-	if string(indexData) != expectedIndexEntry {
-		t.Errorf("Index file content mismatch.\nExpected: %s\nGot: %s",
-			expectedIndexEntry, string(indexData),
+	if len(*index) != 1 ||
+		(*index)[0].Hash != expectedHash || (*index)[0].Filepath != testFileName {
+		t.Errorf("Index content mismatch.\nExpected: %s %s\nGot: %v",
+			expectedHash, testFileName, *index,
 		)
 	}
+	if (*index)[0].Stat.Size != int64(len(testFileContent)) || (*index)[0].Stat.MTime == 0 {
+		t.Errorf("Index entry is missing stat data: %+v", (*index)[0].Stat)
+	}
 }
 
 func computeHash(data []byte) string {
@@ -56,3 +74,17 @@ func computeHash(data []byte) string {
 	h.Write(data)
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
+
+// readObjectFile returns the decompressed content of an object file
+func readObjectFile(path string) ([]byte, error) {
+	compressed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
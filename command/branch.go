@@ -14,7 +14,7 @@ func Branch(name string) error {
 }
 
 func ListBranches() error {
-	if err := internal.ListBranches(); err != nil {
+	if err := internal.ListBranches(colorNone != ""); err != nil {
 		return err
 	}
 	return nil
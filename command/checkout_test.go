@@ -0,0 +1,75 @@
+package command
+
+import (
+	"jit/internal"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCheckoutChangeDetection(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.WriteFile("file.txt", []byte("one\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := Add([]string{"file.txt"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := Commit("first"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := Branch("other"); err != nil {
+		t.Fatalf("Branch failed: %v", err)
+	}
+
+	// testing
+	t.Run("Modified files block checkout", func(t *testing.T) {
+		info, err := os.Stat("file.txt")
+		if err != nil {
+			t.Fatalf("Failed to stat test file: %v", err)
+		}
+		// same size and modification time, only the content differs
+		if err := os.WriteFile("file.txt", []byte("two\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := os.Chtimes("file.txt", info.ModTime(), info.ModTime()); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+
+		if err := Checkout("other"); err == nil {
+			t.Errorf("Expected checkout to fail with a modified file")
+		}
+	})
+
+	t.Run("Clean files keep their stat data", func(t *testing.T) {
+		if err := os.WriteFile("file.txt", []byte("one\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		// the index must be newer than the file for its stat data to be trusted
+		time.Sleep(10 * time.Millisecond)
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Checkout("other"); err != nil {
+			t.Fatalf("Checkout failed: %v", err)
+		}
+
+		index, err := internal.ReadIndex(".")
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		info, err := os.Stat("file.txt")
+		if err != nil {
+			t.Fatalf("Failed to stat test file: %v", err)
+		}
+		if len(*index) != 1 || (*index)[0].Stat.MTime != info.ModTime().UnixNano() {
+			t.Errorf("Expected the index to keep the stat data of file.txt, got %v", *index)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"bytes"
+	"jit/internal"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkedFiles(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := internal.SetChunkThreshold(".", "256k"); err != nil {
+		t.Fatalf("Failed to set chunk threshold: %v", err)
+	}
+
+	content := make([]byte, 2<<20)
+	rand.New(rand.NewSource(1)).Read(content)
+	store := internal.NewLooseObjectStore(".")
+	countObjects := func() int {
+		n := 0
+		store.Iterate(func(string) error {
+			n++
+			return nil
+		})
+		return n
+	}
+
+	commitContent := func(data []byte) {
+		if err := os.WriteFile("data.bin", data, 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"data.bin"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit("version"); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+
+	// testing
+	commitContent(content)
+	first := countObjects()
+	if first < 8 {
+		t.Fatalf("Expected a 2MB file to be split into chunks, got %d objects", first)
+	}
+
+	// insert bytes in the middle, only the chunks around the edit change
+	edited := append(append(append([]byte{}, content[:1<<20]...), []byte("inserted")...), content[1<<20:]...)
+	commitContent(edited)
+	if added := countObjects() - first; added > 6 {
+		t.Errorf("Expected the edit to add a few objects, got %d", added)
+	}
+
+	t.Run("The threshold is fixed once objects are stored", func(t *testing.T) {
+		if err := Config([]string{"set", "core.chunkthreshold", "1m"}, false); err == nil {
+			t.Errorf("Expected changing the threshold to fail")
+		}
+		if err := Config([]string{"unset", "core.chunkthreshold"}, false); err == nil {
+			t.Errorf("Expected unsetting the threshold to fail")
+		}
+		if err := Config([]string{"set", "core.chunkthreshold", "256k"}, false); err != nil {
+			t.Errorf("Expected setting the same threshold to succeed, got %v", err)
+		}
+		status, err := internal.GetStatus()
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		if len(status.Unstaged) != 0 {
+			t.Errorf("Expected a clean working tree, got %v", status.Unstaged)
+		}
+	})
+
+	t.Run("Chunked files are checked and kept by gc", func(t *testing.T) {
+		// the second run finds the chunked objects packed by the first
+		for i := 0; i < 2; i++ {
+			if err := GC(false, "now"); err != nil {
+				t.Fatalf("GC failed: %v", err)
+			}
+			if err := Fsck(); err != nil {
+				t.Errorf("Fsck failed: %v", err)
+			}
+		}
+	})
+
+	t.Run("Chunked files are reassembled", func(t *testing.T) {
+		if err := Clone(".", "clone"); err != nil {
+			t.Fatalf("Clone failed: %v", err)
+		}
+		cloned, err := os.ReadFile(filepath.Join("clone", "data.bin"))
+		if err != nil {
+			t.Fatalf("Failed to read cloned file: %v", err)
+		}
+		if !bytes.Equal(cloned, edited) {
+			t.Errorf("Cloned file differs from the committed one")
+		}
+	})
+}
@@ -2,19 +2,40 @@ package command
 
 import (
 	"fmt"
+	"io"
 	"jit/internal"
+	"os"
+	"strings"
 	"time"
 )
 
+// messageFlags collects repeated -m flags
+type messageFlags []string
+
+func (m *messageFlags) String() string {
+	return strings.Join(*m, "\n\n")
+}
+
+func (m *messageFlags) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+const commitTemplate = `
+# Please enter the commit message for your changes. Lines starting
+# with '#' will be ignored, and an empty message aborts the commit.
+`
+
 func Commit(message string) error {
+	message = cleanMessage(message, false)
 	if message == "" {
 		return fmt.Errorf(
-			"%sCommit message is missing.%s\nUsage: jit commit -m 'commit message'",
+			"%sCommit message is missing.%s\nUsage: jit commit [-m 'commit message' | -F <file>]",
 			colorRed, colorNone,
 		)
 	}
 
-	commitID, err := internal.CreateCommit(message, time.Now(), nil)
+	commitID, err := internal.CreateCommit(message, time.Now(), internal.CommitOptions{})
 	if err != nil {
 		return fmt.Errorf("Error creating commit: %v\n", err)
 	}
@@ -22,3 +43,88 @@ func Commit(message string) error {
 	fmt.Printf("Committed as %s\n", commitID)
 	return nil
 }
+
+// Amend replaces the last commit with one of the staged files, keeping
+// its message unless <message> is given
+func Amend(message string) error {
+	commitID, err := internal.AmendCommit(cleanMessage(message, false), time.Now())
+	if err != nil {
+		return fmt.Errorf("Error amending commit: %v\n", err)
+	}
+
+	fmt.Printf("Amended as %s\n", commitID)
+	return nil
+}
+
+// commitMessage returns the message of the -m <messages>, joined as
+// paragraphs, or the content of <file>, "-" for stdin. Without either
+// the message is written in the editor
+func commitMessage(messages []string, file string) (string, error) {
+	switch {
+	case len(messages) > 0 && file != "":
+		return "", fmt.Errorf("%s-m and -F can't be used together.%s\nUsage: jit commit [-m 'commit message' | -F <file>]",
+			colorRed, colorNone)
+	case len(messages) > 0:
+		paragraphs := make([]string, 0, len(messages))
+		for _, message := range messages {
+			if message = cleanMessage(message, false); message != "" {
+				paragraphs = append(paragraphs, message)
+			}
+		}
+		return strings.Join(paragraphs, "\n\n"), nil
+	case file == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("Failed to read the commit message: %w", err)
+		}
+		return cleanMessage(string(data), false), nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("Failed to read the commit message: %w", err)
+		}
+		return cleanMessage(string(data), false), nil
+	}
+
+	status, err := internal.GetStatus()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get status: %w", err)
+	}
+	var sb strings.Builder
+	sb.WriteString(commitTemplate)
+	if len(status.Staged) > 0 {
+		sb.WriteString("#\n# Changes to be committed:\n")
+		for _, change := range status.Staged {
+			sb.WriteString(fmt.Sprintf("#\t%s: %s\n", change.Change, change.Path))
+		}
+	}
+	edited, err := editText("COMMIT_EDITMSG", sb.String())
+	if err != nil {
+		return "", err
+	}
+	return cleanMessage(edited, true), nil
+}
+
+// cleanMessage strips trailing whitespace from the lines of <message>,
+// collapses runs of blank lines and drops the leading and trailing ones.
+// Lines starting with # are removed when <stripComments> is set
+func cleanMessage(message string, stripComments bool) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(message, "\n") {
+		if stripComments && strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
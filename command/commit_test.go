@@ -0,0 +1,286 @@
+package command
+
+import (
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommitMessages(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	version := 0
+	stage := func() {
+		t.Helper()
+		version++
+		if err := os.WriteFile("file.txt", []byte(strings.Repeat("line\n", version)), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	headMessage := func() string {
+		t.Helper()
+		hash, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		commit, err := internal.LoadCommit(".", hash)
+		if err != nil {
+			t.Fatalf("LoadCommit failed: %v", err)
+		}
+		return commit.Message
+	}
+
+	// testing
+	t.Run("Multi-line messages are kept", func(t *testing.T) {
+		stage()
+		message := "Subject line\n\nFirst paragraph\nstill first\n\nSecond paragraph"
+		if err := Commit(message + "\n\n"); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if got := headMessage(); got != message {
+			t.Errorf("Expected message %q, got %q", message, got)
+		}
+	})
+
+	t.Run("Indented messages are kept", func(t *testing.T) {
+		stage()
+		message := "    indented first line\n\n\tindented body"
+		if err := Commit(message); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if got := headMessage(); got != message {
+			t.Errorf("Expected message %q, got %q", message, got)
+		}
+	})
+
+	t.Run("Repeated -m are joined as paragraphs", func(t *testing.T) {
+		message, err := commitMessage([]string{"Subject", "Body\nmore", "  "}, "")
+		if err != nil {
+			t.Fatalf("commitMessage failed: %v", err)
+		}
+		if expected := "Subject\n\nBody\nmore"; message != expected {
+			t.Errorf("Expected %q, got %q", expected, message)
+		}
+	})
+
+	t.Run("-F reads the message from a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "message")
+		if err := os.WriteFile(path, []byte("From file  \n\n\n\nbody\n"), 0644); err != nil {
+			t.Fatalf("Failed to write message file: %v", err)
+		}
+		message, err := commitMessage(nil, path)
+		if err != nil {
+			t.Fatalf("commitMessage failed: %v", err)
+		}
+		if expected := "From file\n\nbody"; message != expected {
+			t.Errorf("Expected %q, got %q", expected, message)
+		}
+		if _, err := commitMessage([]string{"both"}, path); err == nil {
+			t.Errorf("Expected -m and -F together to fail")
+		}
+	})
+
+	t.Run("Without -m the editor is opened on a template", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("editor script needs a shell")
+		}
+		stage()
+		dir := t.TempDir()
+		template := filepath.Join(dir, "template")
+		editor := filepath.Join(dir, "editor.sh")
+		script := "#!/bin/sh\ncp \"$1\" " + template + "\nprintf 'From editor\\n# a comment\\n\\nBody\\n' >> \"$1\"\n"
+		if err := os.WriteFile(editor, []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to write editor: %v", err)
+		}
+		t.Setenv("VISUAL", editor)
+
+		message, err := commitMessage(nil, "")
+		if err != nil {
+			t.Fatalf("commitMessage failed: %v", err)
+		}
+		if expected := "From editor\n\nBody"; message != expected {
+			t.Errorf("Expected %q, got %q", expected, message)
+		}
+		shown, err := os.ReadFile(template)
+		if err != nil {
+			t.Fatalf("Failed to read template: %v", err)
+		}
+		if !strings.Contains(string(shown), "#\tmodified: file.txt") {
+			t.Errorf("Expected the template to list the staged file, got %q", shown)
+		}
+	})
+
+	t.Run("Empty messages are rejected", func(t *testing.T) {
+		stage()
+		if err := Commit(" \n\n\t\n"); err == nil {
+			t.Errorf("Expected an empty message to be rejected")
+		}
+		if runtime.GOOS == "windows" {
+			return
+		}
+		editor := filepath.Join(t.TempDir(), "editor.sh")
+		if err := os.WriteFile(editor, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+			t.Fatalf("Failed to write editor: %v", err)
+		}
+		t.Setenv("VISUAL", editor)
+		message, err := commitMessage(nil, "")
+		if err != nil {
+			t.Fatalf("commitMessage failed: %v", err)
+		}
+		if err := Commit(message); err == nil {
+			t.Errorf("Expected a template left unchanged to abort the commit")
+		}
+	})
+}
+
+func TestAmend(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := Amend("nothing yet"); err == nil {
+		t.Errorf("Expected amending without commits to fail")
+	}
+
+	commitFile := func(content, message string) string {
+		t.Helper()
+		if err := os.WriteFile("file.txt", []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if message != "" {
+			if err := Commit(message); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+		}
+		hash, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		return hash
+	}
+	loadCommit := func(hash string) *internal.Commit {
+		t.Helper()
+		commit, err := internal.LoadCommit(".", hash)
+		if err != nil {
+			t.Fatalf("LoadCommit failed: %v", err)
+		}
+		return commit
+	}
+
+	first := commitFile("first\n", "first")
+	t.Setenv("JIT_AUTHOR_NAME", "Original Author")
+	typo := commitFile("secnod\n", "add secnod line")
+
+	// testing
+	t.Run("Amend replaces the last commit", func(t *testing.T) {
+		t.Setenv("JIT_AUTHOR_NAME", "Someone Else")
+		commitFile("second\n", "")
+		if err := Amend("add second line"); err != nil {
+			t.Fatalf("Amend failed: %v", err)
+		}
+
+		amended, err := internal.ResolveCommit("master")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		if amended == typo {
+			t.Fatalf("Expected the branch to move to a new commit")
+		}
+		commit := loadCommit(amended)
+		if len(commit.ParentIDs) != 1 || commit.ParentIDs[0] != first {
+			t.Errorf("Expected parents [%s], got %v", first, commit.ParentIDs)
+		}
+		if commit.Message != "add second line" {
+			t.Errorf("Expected the new message, got '%s'", commit.Message)
+		}
+		if commit.Author.Name != "Original Author" {
+			t.Errorf("Expected the author to be kept, got '%s'", commit.Author.Name)
+		}
+		dir := t.TempDir()
+		if err := internal.ExtractTree(".", commit.TreeID, dir); err != nil {
+			t.Fatalf("ExtractTree failed: %v", err)
+		}
+		if content, _ := os.ReadFile(filepath.Join(dir, "file.txt")); string(content) != "second\n" {
+			t.Errorf("Expected the staged content, got '%s'", content)
+		}
+
+		history, err := internal.GetCommitHistory()
+		if err != nil {
+			t.Fatalf("GetCommitHistory failed: %v", err)
+		}
+		if len(history) != 2 {
+			t.Errorf("Expected 2 commits, got %d", len(history))
+		}
+	})
+
+	t.Run("Amend keeps the message when none is given", func(t *testing.T) {
+		head := commitFile("second line\n", "")
+		if err := Amend(""); err != nil {
+			t.Fatalf("Amend failed: %v", err)
+		}
+		amended, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		commit := loadCommit(amended)
+		if commit.Message != loadCommit(head).Message {
+			t.Errorf("Expected the message to be kept, got '%s'", commit.Message)
+		}
+		if len(commit.ParentIDs) != 1 || commit.ParentIDs[0] != first {
+			t.Errorf("Expected parents [%s], got %v", first, commit.ParentIDs)
+		}
+	})
+
+	t.Run("A commit made since HEAD was read is not dropped", func(t *testing.T) {
+		stale, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		latest := commitFile("third\n", "third")
+		_, err = internal.CreateCommit("replaces third", time.Now(), internal.CommitOptions{
+			Parents: []string{first},
+			OldHead: stale,
+		})
+		if err == nil {
+			t.Fatalf("Expected a commit against an outdated HEAD to fail")
+		}
+		if head, _ := internal.ResolveCommit("HEAD"); head != latest {
+			t.Errorf("Expected HEAD to stay at %s, got %s", latest, head)
+		}
+	})
+
+	t.Run("Amending the root commit keeps it a root", func(t *testing.T) {
+		if err := Reset(internal.ResetSoft, []string{first}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		if err := Amend("root"); err != nil {
+			t.Fatalf("Amend failed: %v", err)
+		}
+		amended, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		if parents := loadCommit(amended).ParentIDs; len(parents) != 0 {
+			t.Errorf("Expected no parents, got %v", parents)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+)
+
+const configUsage = "Usage: jit config [--user] get <key> | set <key> <value> | unset <key> | list"
+
+// Config reads and changes settings, <args> are the action and its
+// arguments. set and unset change the repository config, or the user
+// config when <user> is set
+func Config(args []string, user bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%sNo action specified.%s\n%s", colorRed, colorNone, configUsage)
+	}
+	scope := internal.ConfigRepo
+	if user {
+		scope = internal.ConfigUser
+	}
+
+	action, args := args[0], args[1:]
+	switch {
+	case action == "get" && len(args) == 1:
+		value, ok, err := internal.GetConfig(args[0])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%s'%s' is not set.%s", colorRed, args[0], colorNone)
+		}
+		fmt.Println(value)
+	case action == "set" && len(args) == 2:
+		return internal.SetConfig(scope, args[0], args[1])
+	case action == "unset" && len(args) == 1:
+		return internal.UnsetConfig(scope, args[0])
+	case action == "list" && len(args) == 0:
+		entries, err := internal.ListConfig()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s\t%s = %s\n", entry.Scope, entry.Key, entry.Value)
+		}
+	default:
+		return fmt.Errorf("%sInvalid arguments for '%s'.%s\n%s", colorRed, action, colorNone, configUsage)
+	}
+	return nil
+}
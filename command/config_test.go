@@ -0,0 +1,184 @@
+package command
+
+import (
+	"jit/config"
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	userDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+	t.Setenv(config.EnvName(config.USER_NAME_KEY), "")
+
+	if err := Config([]string{"set", config.DEFAULT_BRANCH_KEY, "main"}, true); err != nil {
+		t.Fatalf("Config set failed: %v", err)
+	}
+	if err := Config([]string{"set", config.DEFAULT_HASH_KEY, internal.SHA256}, true); err != nil {
+		t.Fatalf("Config set failed: %v", err)
+	}
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	get := func(key string) string {
+		t.Helper()
+		value, _, err := internal.GetConfig(key)
+		if err != nil {
+			t.Fatalf("GetConfig failed: %v", err)
+		}
+		return value
+	}
+
+	// testing
+	t.Run("Init uses the configured defaults", func(t *testing.T) {
+		head, err := os.ReadFile(filepath.Join(config.REPO_DIR, config.HEAD_PATH))
+		if err != nil {
+			t.Fatalf("Failed to read HEAD: %v", err)
+		}
+		if string(head) != "ref: refs/heads/main\n" {
+			t.Errorf("Expected HEAD on main, got '%s'", head)
+		}
+		if algorithm := get(config.HASH_ALGORITHM_KEY); algorithm != internal.SHA256 {
+			t.Errorf("Expected hash algorithm sha256, got '%s'", algorithm)
+		}
+	})
+
+	t.Run("The repository config overrides the user config", func(t *testing.T) {
+		if err := Config([]string{"set", "user.name", "User"}, true); err != nil {
+			t.Fatalf("Config set failed: %v", err)
+		}
+		if name := get("user.name"); name != "User" {
+			t.Errorf("Expected 'User', got '%s'", name)
+		}
+		if err := Config([]string{"set", "User.Name", "Repo"}, false); err != nil {
+			t.Fatalf("Config set failed: %v", err)
+		}
+		if name := get("user.name"); name != "Repo" {
+			t.Errorf("Expected 'Repo', got '%s'", name)
+		}
+
+		t.Setenv(config.EnvName("user.name"), "Env")
+		if name := get("user.name"); name != "Env" {
+			t.Errorf("Expected 'Env', got '%s'", name)
+		}
+		entries, err := internal.ListConfig()
+		if err != nil {
+			t.Fatalf("ListConfig failed: %v", err)
+		}
+		expected := []internal.ConfigEntry{
+			{Key: config.HASH_ALGORITHM_KEY, Value: internal.SHA256, Scope: internal.ConfigRepo},
+			{Key: config.DEFAULT_BRANCH_KEY, Value: "main", Scope: internal.ConfigUser},
+			{Key: config.DEFAULT_HASH_KEY, Value: internal.SHA256, Scope: internal.ConfigUser},
+			{Key: "user.name", Value: "Env", Scope: internal.ConfigEnv},
+		}
+		if !reflect.DeepEqual(entries, expected) {
+			t.Errorf("Expected %v, got %v", expected, entries)
+		}
+		t.Setenv(config.EnvName("user.name"), "")
+
+		if err := Config([]string{"unset", "user.name"}, false); err != nil {
+			t.Fatalf("Config unset failed: %v", err)
+		}
+		if name := get("user.name"); name != "User" {
+			t.Errorf("Expected 'User' after unset, got '%s'", name)
+		}
+		if err := Config([]string{"unset", "user.name"}, false); err == nil {
+			t.Errorf("Expected unsetting a missing key to fail")
+		}
+	})
+
+	t.Run("Invalid changes are rejected", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"set", config.HASH_ALGORITHM_KEY, internal.SHA1},
+			{"unset", config.HASH_ALGORITHM_KEY},
+			{"set", "nosection", "value"},
+			{"set", "user.name", "two\nlines"},
+			{"set", config.COLOR_KEY, "blue"},
+			{"set", config.CHUNK_THRESHOLD_KEY, "lots"},
+			{"set", config.DEFAULT_BRANCH_KEY, "a/b"},
+			{"get"},
+			{"frobnicate"},
+		} {
+			if err := Config(args, false); err == nil {
+				t.Errorf("Expected 'jit config %s' to fail", strings.Join(args, " "))
+			}
+		}
+		if err := Config([]string{"set", config.CHUNK_THRESHOLD_KEY, "1m"}, true); err == nil {
+			t.Errorf("Expected core settings in the user config to be rejected")
+		}
+		if err := Config([]string{"set", config.COLOR_KEY, "blue"}, true); err == nil {
+			t.Errorf("Expected an invalid color.ui in the user config to be rejected")
+		}
+		if value := get(config.CHUNK_THRESHOLD_KEY); value != "" {
+			t.Errorf("Expected no chunk threshold, got '%s'", value)
+		}
+		if algorithm := get(config.HASH_ALGORITHM_KEY); algorithm != internal.SHA256 {
+			t.Errorf("Expected hash algorithm to stay sha256, got '%s'", algorithm)
+		}
+	})
+
+	t.Run("Aliases expand to commands", func(t *testing.T) {
+		if err := os.WriteFile("file.txt", []byte("content\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit("first"); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if err := Config([]string{"set", "alias.nb", "branch"}, false); err != nil {
+			t.Fatalf("Config set failed: %v", err)
+		}
+		if err := run("nb", []string{"feature"}, map[string]bool{}); err != nil {
+			t.Fatalf("Alias failed: %v", err)
+		}
+		if _, err := internal.ResolveCommit("feature"); err != nil {
+			t.Errorf("Expected the alias to create branch feature: %v", err)
+		}
+
+		if err := Config([]string{"set", "alias.ping", "pong"}, false); err != nil {
+			t.Fatalf("Config set failed: %v", err)
+		}
+		if err := Config([]string{"set", "alias.pong", "ping"}, false); err != nil {
+			t.Fatalf("Config set failed: %v", err)
+		}
+		if err := run("ping", nil, map[string]bool{}); err == nil {
+			t.Errorf("Expected an alias loop to fail")
+		}
+	})
+
+	t.Run("An invalid color.ui falls back to auto", func(t *testing.T) {
+		saved := []string{colorRed, colorYellow, colorGreen, colorNone}
+		defer func() { colorRed, colorYellow, colorGreen, colorNone = saved[0], saved[1], saved[2], saved[3] }()
+
+		// written by hand, jit config rejects it
+		t.Setenv(config.EnvName(config.COLOR_KEY), "blue")
+		setupColor()
+		if err := Config([]string{"get", "user.name"}, false); err != nil {
+			t.Errorf("Expected commands to keep working, got %v", err)
+		}
+	})
+
+	t.Run("color.ui never turns color off", func(t *testing.T) {
+		saved := []string{colorRed, colorYellow, colorGreen, colorNone}
+		defer func() { colorRed, colorYellow, colorGreen, colorNone = saved[0], saved[1], saved[2], saved[3] }()
+
+		if err := Config([]string{"set", config.COLOR_KEY, "never"}, false); err != nil {
+			t.Fatalf("Config set failed: %v", err)
+		}
+		setupColor()
+		if colorRed != "" || colorYellow != "" || colorGreen != "" || colorNone != "" {
+			t.Errorf("Expected no color codes")
+		}
+	})
+}
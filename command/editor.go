@@ -0,0 +1,43 @@
+package command
+
+import (
+	"fmt"
+	"jit/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// editText opens <text> in the user's editor and returns the edited text.
+// The text is kept in .jit/<name> while it is being edited
+func editText(name, text string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	path := filepath.Join(config.REPO_DIR, name)
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor '%s' failed: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
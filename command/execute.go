@@ -3,7 +3,10 @@ package command
 import (
 	"flag"
 	"fmt"
+	"jit/config"
+	"jit/internal"
 	"os"
+	"strings"
 )
 
 func Execute() error {
@@ -11,22 +14,108 @@ func Execute() error {
 		return fmt.Errorf("Usage: jit <command> [options]")
 	}
 
-	command := os.Args[1]
-	args := os.Args[2:]
+	setupColor()
+	return run(os.Args[1], os.Args[2:], map[string]bool{})
+}
 
+// run runs <command> with <args>, <expanded> holds
+// the aliases already expanded to get there
+func run(command string, args []string, expanded map[string]bool) error {
 	switch command {
 	case "init":
 		return Init(args)
 	case "add":
-		paths := args
+		addFlag := flag.NewFlagSet("add", flag.ExitOnError)
+		all := addFlag.Bool("A", false, "Stage all additions, modifications and deletions")
+		update := addFlag.Bool("u", false, "Stage modifications and deletions of tracked files only")
+		patch := addFlag.Bool("p", false, "Choose the hunks of tracked files to stage")
+		_ = addFlag.Parse(args)
+		paths := addFlag.Args()
+		switch {
+		case *patch && (*all || *update):
+			return fmt.Errorf("%s-p can't be used with -A or -u.%s\nUsage: jit add -p <path>",
+				colorRed, colorNone)
+		case *patch:
+			return AddPatch(paths)
+		case *all && *update:
+			return fmt.Errorf("%s-A and -u can't be used together.%s\nUsage: jit add [-A | -u] <file1> <file2> ...",
+				colorRed, colorNone)
+		case *all:
+			return AddAll(paths)
+		case *update:
+			return AddUpdate(paths)
+		}
 		return Add(paths)
+	case "rm":
+		rmFlag := flag.NewFlagSet("rm", flag.ExitOnError)
+		cached := rmFlag.Bool("cached", false, "Only remove the files from the index")
+		force := rmFlag.Bool("f", false, "Remove files even if they have changes")
+		_ = rmFlag.Parse(args)
+		return Rm(rmFlag.Args(), *cached, *force)
+	case "mv":
+		if len(args) != 2 {
+			return fmt.Errorf(
+				"%sPlease provide a source and a destination.%s\nUsage: jit mv <source> <destination>",
+				colorRed, colorNone)
+		}
+		return Mv(args[0], args[1])
+	case "reset":
+		resetFlag := flag.NewFlagSet("reset", flag.ExitOnError)
+		soft := resetFlag.Bool("soft", false, "Only move HEAD")
+		mixed := resetFlag.Bool("mixed", false, "Move HEAD and reset the index (default)")
+		hard := resetFlag.Bool("hard", false, "Move HEAD, reset the index and the working directory")
+		_ = resetFlag.Parse(args)
+
+		mode := ""
+		for name, set := range map[string]bool{
+			internal.ResetSoft:  *soft,
+			internal.ResetMixed: *mixed,
+			internal.ResetHard:  *hard,
+		} {
+			if set && mode != "" {
+				return fmt.Errorf("%sOnly one of --soft, --mixed and --hard can be given.%s\nUsage: jit reset [--soft | --mixed | --hard] [<commit>]",
+					colorRed, colorNone)
+			}
+			if set {
+				mode = name
+			}
+		}
+		return Reset(mode, resetFlag.Args())
+	case "restore":
+		restoreFlag := flag.NewFlagSet("restore", flag.ExitOnError)
+		source := restoreFlag.String("source", "", "Commit to take the files from")
+		staged := restoreFlag.Bool("staged", false, "Restore the staging area instead of the working directory")
+		_ = restoreFlag.Parse(args)
+		return Restore(restoreFlag.Args(), *source, *staged)
 	case "commit":
 		msgFlag := flag.NewFlagSet("commit", flag.ExitOnError)
-		msg := msgFlag.String("m", "", "Commit message")
+		var messages messageFlags
+		msgFlag.Var(&messages, "m", "Commit message, repeated -m are separate paragraphs")
+		file := msgFlag.String("F", "", "Read the commit message from a file, - for stdin")
+		amend := msgFlag.Bool("amend", false, "Replace the last commit, keeping its message unless one is given")
 		_ = msgFlag.Parse(args)
-		return Commit(*msg)
+		if *amend && len(messages) == 0 && *file == "" {
+			return Amend("")
+		}
+		message, err := commitMessage(messages, *file)
+		if err != nil {
+			return err
+		}
+		if *amend {
+			if message == "" {
+				return fmt.Errorf("%sCommit message is missing.%s\nUsage: jit commit --amend [-m 'commit message' | -F <file>]",
+					colorRed, colorNone)
+			}
+			return Amend(message)
+		}
+		return Commit(message)
 	case "log":
 		return Log()
+	case "status":
+		statusFlag := flag.NewFlagSet("status", flag.ExitOnError)
+		porcelain := statusFlag.Bool("porcelain", false, "Machine-readable output")
+		_ = statusFlag.Parse(args)
+		return Status(*porcelain)
 	case "branch":
 		if len(args) == 0 {
 			return ListBranches()
@@ -66,7 +155,33 @@ func Execute() error {
 				colorRed, colorNone)
 		}
 		return Clone(args[0], args[1])
+	case "migrate":
+		return Migrate()
+	case "repack":
+		return Repack()
+	case "fsck":
+		return Fsck()
+	case "gc":
+		gcFlag := flag.NewFlagSet("gc", flag.ExitOnError)
+		dryRun := gcFlag.Bool("dry-run", false, "List unreachable objects without removing them")
+		prune := gcFlag.String("prune", "2w", "Only remove unreachable objects older than this")
+		_ = gcFlag.Parse(args)
+		return GC(*dryRun, *prune)
+	case "config":
+		configFlag := flag.NewFlagSet("config", flag.ExitOnError)
+		user := configFlag.Bool("user", false, "Use the user config instead of the repository config")
+		_ = configFlag.Parse(args)
+		return Config(configFlag.Args(), *user)
 	default:
-		return fmt.Errorf("Unknown command: %s", command)
+		alias, _, _ := internal.GetConfig(config.ALIAS_PREFIX + command)
+		fields := strings.Fields(alias)
+		if len(fields) == 0 {
+			return fmt.Errorf("Unknown command: %s", command)
+		}
+		if expanded[command] {
+			return fmt.Errorf("%sAlias loop at '%s'.%s", colorRed, command, colorNone)
+		}
+		expanded[command] = true
+		return run(fields[0], append(fields[1:], args...), expanded)
 	}
 }
@@ -0,0 +1,29 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+)
+
+// Fsck prints one line per problem: <kind> <type> <hash> [<reason>]
+// dangling objects are reported but are not errors, `jit gc` removes them
+func Fsck() error {
+	problems, err := internal.CheckObjects()
+	if err != nil {
+		return fmt.Errorf("Failed to check objects: %w", err)
+	}
+
+	errors := 0
+	for _, problem := range problems {
+		fmt.Println(problem)
+		if problem.Kind != internal.FsckDangling {
+			errors++
+		}
+	}
+
+	if errors > 0 {
+		return fmt.Errorf("%sfsck found %d missing or corrupt objects.%s",
+			colorRed, errors, colorNone)
+	}
+	return nil
+}
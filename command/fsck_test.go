@@ -0,0 +1,112 @@
+package command
+
+import (
+	"jit/config"
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestFsck(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(name, []byte(name+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{name}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := Commit("fsck"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// testing
+	t.Run("Healthy repository", func(t *testing.T) {
+		if err := Fsck(); err != nil {
+			t.Fatalf("Fsck failed on a healthy repository: %v", err)
+		}
+	})
+
+	t.Run("Dangling objects are not errors", func(t *testing.T) {
+		if err := os.WriteFile("a.txt", []byte("changed\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"a.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		problems, err := internal.CheckObjects()
+		if err != nil {
+			t.Fatalf("CheckObjects failed: %v", err)
+		}
+		if len(problems) != 0 {
+			t.Fatalf("Expected no problems, got %v", problems)
+		}
+
+		// unstaged again, the old blob is still used by the commit and
+		// the new one is dangling
+		if err := os.WriteFile("a.txt", []byte("changed again\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"a.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		problems, err = internal.CheckObjects()
+		if err != nil {
+			t.Fatalf("CheckObjects failed: %v", err)
+		}
+		if len(problems) != 1 || problems[0].Kind != internal.FsckDangling ||
+			problems[0].Hash != blobHash([]byte("changed\n")) {
+			t.Errorf("Expected one dangling blob, got %v", problems)
+		}
+		if err := Fsck(); err != nil {
+			t.Errorf("Fsck failed on dangling objects: %v", err)
+		}
+	})
+
+	t.Run("Missing and corrupt objects", func(t *testing.T) {
+		missing := blobHash([]byte("b.txt\n"))
+		if err := os.Remove(objectFile(missing)); err != nil {
+			t.Fatalf("Failed to remove object: %v", err)
+		}
+
+		corrupt := blobHash([]byte("changed again\n"))
+		if err := os.WriteFile(objectFile(corrupt), []byte("garbage"), 0644); err != nil {
+			t.Fatalf("Failed to corrupt object: %v", err)
+		}
+
+		problems, err := internal.CheckObjects()
+		if err != nil {
+			t.Fatalf("CheckObjects failed: %v", err)
+		}
+		var got []string
+		for _, p := range problems {
+			if p.Kind != internal.FsckDangling {
+				got = append(got, p.Kind+" "+p.Hash)
+			}
+		}
+		expected := []string{"missing " + missing, "corrupt " + corrupt}
+		sort.Strings(got)
+		sort.Strings(expected)
+		if len(got) != 2 || got[0] != expected[0] || got[1] != expected[1] {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+
+		if err := Fsck(); err == nil {
+			t.Errorf("Expected fsck to fail, got nil")
+		}
+	})
+}
+
+// Returns the path of the loose object with <hash>
+func objectFile(hash string) string {
+	return filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, hash[:2], hash[2:])
+}
@@ -0,0 +1,66 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func GC(dryRun bool, prune string) error {
+	gracePeriod, err := parseGracePeriod(prune)
+	if err != nil {
+		return fmt.Errorf(
+			"%sInvalid prune period '%s'.%s\nUsage: jit gc [--dry-run] [--prune=<2w|14d|72h|now>]",
+			colorRed, prune, colorNone)
+	}
+
+	result, err := internal.CollectGarbage(internal.GCOptions{
+		DryRun:      dryRun,
+		GracePeriod: gracePeriod,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to collect garbage: %w", err)
+	}
+
+	if dryRun {
+		for _, hash := range result.Pruned {
+			fmt.Printf("Would remove %s\n", hash)
+		}
+		fmt.Printf("%d unreachable objects would be removed.\n", len(result.Pruned))
+		return nil
+	}
+
+	fmt.Printf("Removed %d unreachable objects.\n", len(result.Pruned))
+	fmt.Printf("Packed %d objects.\n", result.Packed)
+	return nil
+}
+
+// parseGracePeriod parses "now", days ("14d"), weeks ("2w")
+// or any Go duration ("72h")
+func parseGracePeriod(period string) (time.Duration, error) {
+	if period == "now" {
+		return 0, nil
+	}
+
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if n, found := strings.CutSuffix(period, suffix); found {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid period '%s'", period)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid period '%s'", period)
+	}
+	return d, nil
+}
@@ -0,0 +1,80 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+	"os"
+	"testing"
+)
+
+func TestGC(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	orphan := []byte("staged, then changed\n")
+	kept := []byte("committed\n")
+	for _, content := range [][]byte{orphan, kept} {
+		if err := os.WriteFile("file.txt", content, 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := Commit("keep"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	orphanHash := blobHash(orphan)
+	keptHash := blobHash(kept)
+	store := internal.NewLooseObjectStore(".")
+
+	// testing
+	t.Run("Recent objects survive the grace period", func(t *testing.T) {
+		if err := GC(false, "2w"); err != nil {
+			t.Fatalf("GC failed: %v", err)
+		}
+		if !store.Has(orphanHash) {
+			t.Errorf("Recent unreachable object %s was removed", orphanHash)
+		}
+	})
+
+	t.Run("Dry run removes nothing", func(t *testing.T) {
+		if err := GC(true, "now"); err != nil {
+			t.Fatalf("GC failed: %v", err)
+		}
+		if !store.Has(orphanHash) {
+			t.Errorf("Dry run removed %s", orphanHash)
+		}
+	})
+
+	t.Run("Unreachable objects are pruned", func(t *testing.T) {
+		if err := GC(false, "now"); err != nil {
+			t.Fatalf("GC failed: %v", err)
+		}
+		if store.Has(orphanHash) {
+			t.Errorf("Unreachable object %s was not removed", orphanHash)
+		}
+		if !store.Has(keptHash) {
+			t.Errorf("Reachable object %s was removed", keptHash)
+		}
+		if err := Log(); err != nil {
+			t.Errorf("Log failed after gc: %v", err)
+		}
+	})
+
+	t.Run("Invalid prune period", func(t *testing.T) {
+		if err := GC(false, "soon"); err == nil {
+			t.Errorf("Expected error for invalid prune period, got nil")
+		}
+	})
+}
+
+func blobHash(content []byte) string {
+	return computeHash([]byte(fmt.Sprintf("blob %d\x00%s", len(content), content)))
+}
@@ -0,0 +1,125 @@
+package command
+
+import (
+	"fmt"
+	"jit/config"
+	"jit/internal"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCommitIdentity(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, name := range []string{"NAME", "EMAIL", "DATE"} {
+		t.Setenv("JIT_AUTHOR_"+name, "")
+		t.Setenv("JIT_COMMITTER_"+name, "")
+	}
+	t.Setenv(config.EnvName(config.USER_NAME_KEY), "")
+	t.Setenv(config.EnvName(config.USER_EMAIL_KEY), "")
+	if err := Config([]string{"set", config.USER_NAME_KEY, "Jane Doe"}, true); err != nil {
+		t.Fatalf("Config set failed: %v", err)
+	}
+	if err := Config([]string{"set", config.USER_EMAIL_KEY, "jane@example.com"}, true); err != nil {
+		t.Fatalf("Config set failed: %v", err)
+	}
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	commitFile := func(content, message string) *internal.Commit {
+		t.Helper()
+		if err := os.WriteFile("file.txt", []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit(message); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		hash, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		commit, err := internal.LoadCommit(".", hash)
+		if err != nil {
+			t.Fatalf("LoadCommit failed: %v", err)
+		}
+		return commit
+	}
+
+	// testing
+	t.Run("Identity comes from the user config", func(t *testing.T) {
+		commit := commitFile("one\n", "first")
+		for _, sig := range []internal.Signature{commit.Author, commit.Committer} {
+			if sig.Name != "Jane Doe" || sig.Email != "jane@example.com" {
+				t.Errorf("Expected Jane Doe <jane@example.com>, got %s <%s>", sig.Name, sig.Email)
+			}
+			if sig.When.Unix() != commit.Timestamp.Unix() {
+				t.Errorf("Expected signature time %v, got %v", commit.Timestamp, sig.When)
+			}
+		}
+	})
+
+	t.Run("Environment overrides the config and keeps the time zone", func(t *testing.T) {
+		t.Setenv("JIT_AUTHOR_NAME", "John Roe")
+		t.Setenv("JIT_AUTHOR_EMAIL", "john@example.com")
+		t.Setenv("JIT_AUTHOR_DATE", "1700000000 +0530")
+
+		commit := commitFile("two\n", "second")
+		if commit.Author.Name != "John Roe" || commit.Author.Email != "john@example.com" {
+			t.Errorf("Expected author John Roe <john@example.com>, got %s <%s>",
+				commit.Author.Name, commit.Author.Email)
+		}
+		if commit.Committer.Name != "Jane Doe" {
+			t.Errorf("Expected committer Jane Doe, got %s", commit.Committer.Name)
+		}
+		if commit.Author.When.Unix() != 1700000000 {
+			t.Errorf("Expected author time 1700000000, got %d", commit.Author.When.Unix())
+		}
+		if _, offset := commit.Author.When.Zone(); offset != 5*3600+30*60 {
+			t.Errorf("Expected offset +0530, got %d seconds", offset)
+		}
+
+		problems, err := internal.CheckObjects()
+		if err != nil {
+			t.Fatalf("CheckObjects failed: %v", err)
+		}
+		if len(problems) != 0 {
+			t.Errorf("Expected no problems, got %v", problems)
+		}
+	})
+
+	t.Run("Commits without identity are still read", func(t *testing.T) {
+		head, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		commit, err := internal.LoadCommit(".", head)
+		if err != nil {
+			t.Fatalf("LoadCommit failed: %v", err)
+		}
+		legacy := fmt.Sprintf("tree %s\nparent %s\ntimestamp 1600000000\n\nold commit\n", commit.TreeID, head)
+		hash, err := internal.NewLooseObjectStore(".").Put("commit", []byte(legacy))
+		if err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+
+		loaded, err := internal.LoadCommit(".", hash)
+		if err != nil {
+			t.Fatalf("LoadCommit failed: %v", err)
+		}
+		if !loaded.Author.IsZero() || !loaded.Committer.IsZero() {
+			t.Errorf("Expected no identity, got %v and %v", loaded.Author, loaded.Committer)
+		}
+		if loaded.Message != "old commit" || !loaded.Timestamp.Equal(time.Unix(1600000000, 0)) {
+			t.Errorf("Unexpected commit %+v", loaded)
+		}
+	})
+}
@@ -1,15 +1,42 @@
 package command
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"jit/config"
+	"jit/internal"
 	"os"
 	"path/filepath"
 )
 
 func Init(args []string) error {
-	if len(args) > 0 {
-		return fmt.Errorf("%sToo many arguements.%s\nUsage: jit init", colorRed, colorNone)
+	initFlag := flag.NewFlagSet("init", flag.ContinueOnError)
+	initFlag.SetOutput(io.Discard)
+	defaultHash, ok, _ := internal.GetConfig(config.DEFAULT_HASH_KEY)
+	if !ok {
+		defaultHash = internal.SHA1
+	}
+	hashAlgorithm := initFlag.String("hash", defaultHash, "Object hash algorithm: sha1 or sha256")
+	if err := initFlag.Parse(args); err != nil {
+		return fmt.Errorf("%s%s.%s\nUsage: jit init [--hash=sha1|sha256]",
+			colorRed, err, colorNone)
+	}
+	if initFlag.NArg() > 0 {
+		return fmt.Errorf("%sToo many arguements.%s\nUsage: jit init [--hash=sha1|sha256]",
+			colorRed, colorNone)
+	}
+	if !internal.IsHashAlgorithm(*hashAlgorithm) {
+		return fmt.Errorf("%sUnsupported hash algorithm '%s'.%s\nUsage: jit init [--hash=sha1|sha256]",
+			colorRed, *hashAlgorithm, colorNone)
+	}
+
+	branch, ok, _ := internal.GetConfig(config.DEFAULT_BRANCH_KEY)
+	if !ok {
+		branch = "master"
+	}
+	if !internal.IsBranchName(branch) {
+		return fmt.Errorf("%sInvalid %s '%s'.%s", colorRed, config.DEFAULT_BRANCH_KEY, branch, colorNone)
 	}
 
 	if _, err := os.Stat(config.REPO_DIR); err == nil {
@@ -37,9 +64,14 @@ func Init(args []string) error {
 		}
 	}
 
+	// create .jit/config
+	if err := internal.SetHashAlgorithm(".", *hashAlgorithm); err != nil {
+		return fmt.Errorf("Failed to write config: %s", err)
+	}
+
 	// create .jit/HEAD
 	headFilePath := filepath.Join(config.REPO_DIR, config.HEAD_PATH)
-	headFileContent := []byte("ref: refs/heads/master\n")
+	headFileContent := []byte(fmt.Sprintf("ref: refs/heads/%s\n", branch))
 	if err := os.WriteFile(headFilePath, headFileContent, 0644); err != nil {
 		return fmt.Errorf("Failed to write to file: %s\n%s",
 			headFilePath, err,
@@ -1,12 +1,15 @@
 package command
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
 	"jit/config"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -73,3 +76,58 @@ func TestInit(t *testing.T) {
 		}
 	})
 }
+
+func TestInitSHA256(t *testing.T) {
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{"--hash=sha256"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(config.REPO_DIR, config.CONFIG_PATH))
+	if err != nil {
+		t.Fatalf("Failed to read .jit/config: %v", err)
+	}
+	if !strings.Contains(string(content), "core.hashalgorithm = sha256") {
+		t.Errorf("Hash algorithm not recorded in config: %q", string(content))
+	}
+
+	fileContent := []byte("hashed with sha256\n")
+	if err := os.WriteFile("file.txt", fileContent, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := Add([]string{"file.txt"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("blob %d\x00%s", len(fileContent), fileContent)))
+	expectedHash := hex.EncodeToString(sum[:])
+	if _, err := os.Stat(objectFile(expectedHash)); err != nil {
+		t.Errorf("Expected object file does not exist: %s", objectFile(expectedHash))
+	}
+
+	if err := Commit("sha256"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	ref, err := os.ReadFile(filepath.Join(config.REPO_DIR, config.REFS_DIR, "heads", "master"))
+	if err != nil {
+		t.Fatalf("Failed to read master ref: %v", err)
+	}
+	if len(strings.TrimSpace(string(ref))) != 64 {
+		t.Errorf("Expected a 64 character commit id, got %q", string(ref))
+	}
+
+	if err := Repack(); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if err := Fsck(); err != nil {
+		t.Errorf("Fsck failed: %v", err)
+	}
+
+	t.Run("Fail on unknown hash algorithm", func(t *testing.T) {
+		if err := Init([]string{"--hash=md5"}); err == nil {
+			t.Errorf("Expected error for unknown hash algorithm, got nil")
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"jit/config"
+	"jit/internal"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLocking(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	indexLock := filepath.Join(config.REPO_DIR, "index.lock")
+
+	// testing
+	t.Run("Concurrent adds keep every entry", func(t *testing.T) {
+		const files = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, files)
+		for i := 0; i < files; i++ {
+			name := fmt.Sprintf("file%d.txt", i)
+			if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- Add([]string{name})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+		}
+
+		index, err := internal.ReadIndex(".")
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		if n := len(*index); n != files {
+			t.Errorf("Expected %d index entries, got %d", files, n)
+		}
+	})
+
+	t.Run("A held lock is reported", func(t *testing.T) {
+		lock := fmt.Sprintf("%d\n", os.Getpid())
+		if err := os.WriteFile(indexLock, []byte(lock), 0644); err != nil {
+			t.Fatalf("Failed to write lock: %v", err)
+		}
+		defer os.Remove(indexLock)
+
+		err := Add([]string{"file0.txt"})
+		if !errors.Is(err, internal.ErrLocked) {
+			t.Errorf("Expected a lock error, got %v", err)
+		}
+	})
+
+	t.Run("Stale locks are removed", func(t *testing.T) {
+		// the pid of a process that has exited
+		cmd := exec.Command("go", "version")
+		if err := cmd.Run(); err != nil {
+			t.Skipf("Failed to run a process: %v", err)
+		}
+		lock := fmt.Sprintf("%d\n", cmd.Process.Pid)
+		if err := os.WriteFile(indexLock, []byte(lock), 0644); err != nil {
+			t.Fatalf("Failed to write lock: %v", err)
+		}
+
+		if err := Add([]string{"file0.txt"}); err != nil {
+			t.Errorf("Add failed: %v", err)
+		}
+		if _, err := os.Stat(indexLock); !os.IsNotExist(err) {
+			t.Errorf("Expected the stale lock to be removed")
+		}
+
+		t.Run("Competing lock breakers keep every entry", func(t *testing.T) {
+			const rounds, adders = 5, 24
+			for round := 0; round < rounds; round++ {
+				if err := os.WriteFile(indexLock, []byte(lock), 0644); err != nil {
+					t.Fatalf("Failed to write lock: %v", err)
+				}
+				before, err := internal.ReadIndex(".")
+				if err != nil {
+					t.Fatalf("Failed to read index: %v", err)
+				}
+
+				// every adder finds the stale lock and tries to break it
+				var wg sync.WaitGroup
+				errs := make(chan error, adders)
+				for i := 0; i < adders; i++ {
+					name := fmt.Sprintf("stale%d-%d.txt", round, i)
+					if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+						t.Fatalf("Failed to write test file: %v", err)
+					}
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						errs <- Add([]string{name})
+					}()
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					if err != nil {
+						t.Fatalf("Add failed: %v", err)
+					}
+				}
+
+				after, err := internal.ReadIndex(".")
+				if err != nil {
+					t.Fatalf("Failed to read index: %v", err)
+				}
+				if n := len(*after) - len(*before); n != adders {
+					t.Fatalf("Expected %d new index entries, got %d", adders, n)
+				}
+				for _, path := range []string{indexLock, indexLock + ".lock"} {
+					if _, err := os.Stat(path); !os.IsNotExist(err) {
+						t.Errorf("Expected %s to be removed", path)
+					}
+				}
+			}
+		})
+	})
+}
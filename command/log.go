@@ -2,32 +2,80 @@ package command
 
 import (
 	"fmt"
+	"io"
+	"jit/config"
 	"jit/internal"
 	"os"
 	"strings"
 )
 
-const (
+// emptied by setupColor when color is off
+var (
 	colorRed    = "\033[0;31m"
 	colorYellow = "\033[0;33m"
 	colorNone   = "\033[0m"
 )
 
+// setupColor turns color off unless color.ui is always,
+// or auto (the default) and stdout is a terminal.
+// An invalid color.ui is warned about and treated as auto
+// so that it can still be fixed with jit config
+func setupColor() {
+	value, ok, _ := internal.GetConfig(config.COLOR_KEY)
+	if ok && !internal.IsColorSetting(value) {
+		fmt.Fprintf(os.Stderr, "warning: invalid %s '%s', expected auto, always or never\n",
+			config.COLOR_KEY, value)
+	}
+	enabled := true
+	switch strings.ToLower(value) {
+	case "always", "true":
+	case "never", "false":
+		enabled = false
+	default:
+		enabled = isTerminal(os.Stdout)
+	}
+	if !enabled {
+		colorRed, colorYellow, colorGreen, colorNone = "", "", "", ""
+	}
+}
+
+// isTerminal reports whether <f> is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func Log() error {
 	commits, err := internal.GetCommitHistory()
 	if err != nil {
 		return err
 	}
+	writeLog(os.Stdout, commits)
+	return nil
+}
+
+// writeLog prints <commits> with their author, and their committer
+// when it differs from the author, e.g. for amended commits
+func writeLog(w io.Writer, commits []internal.Commit) {
 	for _, commit := range commits {
 
-		fmt.Fprintf(os.Stdout, "%sCommit  %s%s\n", colorYellow, commit.Hash, colorNone)
+		fmt.Fprintf(w, "%sCommit  %s%s\n", colorYellow, commit.Hash, colorNone)
 
 		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("Date: %s\n", commit.Timestamp))
-		sb.WriteString(fmt.Sprintf("\n\t%s\n", commit.Message))
+		date := commit.Timestamp
+		if !commit.Author.IsZero() {
+			// commits made before identities were recorded have no author
+			sb.WriteString(fmt.Sprintf("Author: %s <%s>\n", commit.Author.Name, commit.Author.Email))
+			date = commit.Author.When
+		}
+		sb.WriteString(fmt.Sprintf("Date: %s\n", date))
+		if c := commit.Committer; !c.IsZero() && c.String() != commit.Author.String() {
+			sb.WriteString(fmt.Sprintf("Committer: %s <%s>\n", c.Name, c.Email))
+			sb.WriteString(fmt.Sprintf("CommitDate: %s\n", c.When))
+		}
+		sb.WriteString(fmt.Sprintf("\n\t%s\n", strings.ReplaceAll(commit.Message, "\n", "\n\t")))
 
-		fmt.Fprintf(os.Stdout, "%s\n", sb.String())
+		fmt.Fprintf(w, "%s\n", sb.String())
 
 	}
-	return nil
 }
@@ -0,0 +1,104 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"jit/internal"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCommitHistory(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	const commits = 5
+	for i := 0; i < commits; i++ {
+		if err := os.WriteFile("file.txt", []byte(fmt.Sprintf("version %d\n", i)), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit(fmt.Sprintf("commit %d", i)); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+
+	// testing
+	history, err := internal.GetCommitHistory()
+	if err != nil {
+		t.Fatalf("GetCommitHistory failed: %v", err)
+	}
+	if len(history) != commits {
+		t.Fatalf("Expected %d commits, got %d", commits, len(history))
+	}
+	for i, commit := range history {
+		expected := fmt.Sprintf("commit %d", commits-1-i)
+		if commit.Message != expected {
+			t.Errorf("Expected commit %d to be '%s', got '%s'", i, expected, commit.Message)
+		}
+	}
+
+	t.Run("Loaded commits can't modify the cache", func(t *testing.T) {
+		commit, err := internal.LoadCommit(".", history[0].Hash)
+		if err != nil {
+			t.Fatalf("LoadCommit failed: %v", err)
+		}
+		commit.Message = "changed"
+		commit.ParentIDs[0] = "changed"
+
+		reloaded, err := internal.LoadCommit(".", history[0].Hash)
+		if err != nil {
+			t.Fatalf("LoadCommit failed: %v", err)
+		}
+		if reloaded.Message != history[0].Message || reloaded.ParentIDs[0] != history[1].Hash {
+			t.Errorf("Cached commit was modified through a loaded copy")
+		}
+	})
+
+	t.Run("The committer is shown when it differs from the author", func(t *testing.T) {
+		t.Setenv("JIT_AUTHOR_NAME", "Ann Author")
+		t.Setenv("JIT_AUTHOR_EMAIL", "ann@example.com")
+		t.Setenv("JIT_COMMITTER_NAME", "Carl Committer")
+		t.Setenv("JIT_COMMITTER_EMAIL", "carl@example.com")
+		if err := os.WriteFile("file.txt", []byte("committed by someone else\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"file.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit("applied"); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		history, err := internal.GetCommitHistory()
+		if err != nil {
+			t.Fatalf("GetCommitHistory failed: %v", err)
+		}
+
+		var out bytes.Buffer
+		writeLog(&out, history[:2])
+		entries := strings.Split(out.String(), "Commit  ")
+		if len(entries) != 3 {
+			t.Fatalf("Expected 2 log entries, got %q", out.String())
+		}
+		for _, expected := range []string{
+			"Author: Ann Author <ann@example.com>\n",
+			"Committer: Carl Committer <carl@example.com>\n",
+			"CommitDate: ",
+		} {
+			if !strings.Contains(entries[1], expected) {
+				t.Errorf("Expected %q in the log entry, got %q", expected, entries[1])
+			}
+		}
+		if strings.Contains(entries[2], "Committer:") {
+			t.Errorf("Expected no committer line when it matches the author, got %q", entries[2])
+		}
+	})
+}
@@ -0,0 +1,20 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+)
+
+func Migrate() error {
+	converted, err := internal.MigrateObjects(".")
+	if err != nil {
+		return fmt.Errorf("Failed to migrate objects: %w", err)
+	}
+
+	if converted == 0 {
+		fmt.Println("Repository is already up to date.")
+		return nil
+	}
+	fmt.Printf("Converted %d objects to the current object format.\n", converted)
+	return nil
+}
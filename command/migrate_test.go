@@ -0,0 +1,118 @@
+package command
+
+import (
+	"fmt"
+	"jit/config"
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	// a repository in the old format: raw objects named by their plain hash
+	blob := []byte("old content\n")
+	blobHash := computeHash(blob)
+	tree := []byte(fmt.Sprintf("blob old.txt %s\n", blobHash))
+	treeHash := computeHash(tree)
+	commit := []byte(fmt.Sprintf("tree %s\ntimestamp 1700000000\n\nold commit\n", treeHash))
+	commitHash := computeHash(commit)
+
+	objects := map[string][]byte{blobHash: blob, treeHash: tree, commitHash: commit}
+	for hash, data := range objects {
+		path := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, hash)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write legacy object: %v", err)
+		}
+	}
+	masterPath := filepath.Join(config.REPO_DIR, config.REFS_DIR, "heads", "master")
+	if err := os.WriteFile(masterPath, []byte(commitHash+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write master ref: %v", err)
+	}
+	if err := os.WriteFile(repoFile("index"), []byte(blobHash+" old.txt\n"), 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+
+	// testing
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	for hash := range objects {
+		path := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, hash)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Legacy object %s was not removed", hash)
+		}
+	}
+
+	newBlobHash := computeHash([]byte(fmt.Sprintf("blob %d\x00%s", len(blob), blob)))
+	index, err := internal.ReadIndex(".")
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if len(*index) != 1 || (*index)[0].Hash != newBlobHash || (*index)[0].Filepath != "old.txt" {
+		t.Errorf("Index not migrated, got %v", *index)
+	}
+
+	ref, err := os.ReadFile(masterPath)
+	if err != nil {
+		t.Fatalf("Failed to read master ref: %v", err)
+	}
+	newCommitHash := strings.TrimSpace(string(ref))
+	if newCommitHash == commitHash {
+		t.Fatalf("master ref was not rewritten")
+	}
+
+	object, err := readObjectFile(filepath.Join(
+		config.REPO_DIR, config.OBJECTS_DIR, newCommitHash[:2], newCommitHash[2:]))
+	if err != nil {
+		t.Fatalf("Failed to read migrated commit: %v", err)
+	}
+	if !strings.HasPrefix(string(object), "commit ") ||
+		!strings.Contains(string(object), "old commit") {
+		t.Errorf("Unexpected migrated commit: %q", string(object))
+	}
+
+	t.Run("Flat objects are moved into fan-out directories", func(t *testing.T) {
+		flatPath := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, newCommitHash)
+		shardedPath := filepath.Join(
+			config.REPO_DIR, config.OBJECTS_DIR, newCommitHash[:2], newCommitHash[2:])
+		if err := os.Rename(shardedPath, flatPath); err != nil {
+			t.Fatalf("Failed to flatten object: %v", err)
+		}
+
+		// still readable from the flat layout
+		if err := Log(); err != nil {
+			t.Fatalf("Log failed on flat object: %v", err)
+		}
+
+		if err := Migrate(); err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if _, err := os.Stat(shardedPath); err != nil {
+			t.Errorf("Object was not moved to %s", shardedPath)
+		}
+		if _, err := os.Stat(flatPath); !os.IsNotExist(err) {
+			t.Errorf("Flat object %s was not removed", flatPath)
+		}
+	})
+
+	t.Run("Migrating twice is a no-op", func(t *testing.T) {
+		if err := Migrate(); err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		ref2, _ := os.ReadFile(masterPath)
+		if string(ref2) != string(ref) {
+			t.Errorf("master ref changed on second migration")
+		}
+	})
+}
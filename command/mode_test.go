@@ -0,0 +1,97 @@
+package command
+
+import (
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no executable bit on windows")
+	}
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.WriteFile("script.sh", []byte("#!/bin/sh\necho hi\n"), 0755); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := Add([]string{"script.sh"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := Commit("add script"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	first, err := internal.ResolveCommit("HEAD")
+	if err != nil {
+		t.Fatalf("ResolveCommit failed: %v", err)
+	}
+
+	isExecutable := func(path string) bool {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		return info.Mode().Perm()&0o111 != 0
+	}
+
+	// testing
+	t.Run("Mode changes show in status and diff", func(t *testing.T) {
+		if err := os.Chmod("script.sh", 0644); err != nil {
+			t.Fatalf("Failed to change mode: %v", err)
+		}
+		status, err := internal.GetStatus()
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		expected := []internal.FileChange{{Path: "script.sh", Change: internal.StatusModified}}
+		if !reflect.DeepEqual(status.Unstaged, expected) {
+			t.Errorf("Expected unstaged changes %v, got %v", expected, status.Unstaged)
+		}
+
+		if err := Add([]string{"script.sh"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit("drop executable bit"); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		second, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		diffs, err := internal.DiffCommits(first, second)
+		if err != nil {
+			t.Fatalf("DiffCommits failed: %v", err)
+		}
+		if !strings.Contains(diffs["script.sh"], "old mode 100755\nnew mode 100644") {
+			t.Errorf("Expected a mode change in the diff, got %q", diffs["script.sh"])
+		}
+	})
+
+	t.Run("Reset restores the executable bit", func(t *testing.T) {
+		if err := Reset(internal.ResetHard, []string{first}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		if !isExecutable("script.sh") {
+			t.Errorf("Expected script.sh to be executable")
+		}
+	})
+
+	t.Run("Clone keeps the executable bit", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "clone")
+		if err := Clone(".", dst); err != nil {
+			t.Fatalf("Clone failed: %v", err)
+		}
+		if !isExecutable(filepath.Join(dst, "script.sh")) {
+			t.Errorf("Expected the cloned script.sh to be executable")
+		}
+	})
+}
@@ -0,0 +1,15 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+)
+
+func Mv(src, dst string) error {
+	moved, err := internal.MoveFile(src, dst)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Renamed '%s' to '%s'\n", src, moved)
+	return nil
+}
@@ -0,0 +1,79 @@
+package command
+
+import (
+	"jit/internal"
+	"os"
+	"testing"
+)
+
+func TestMv(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(name, []byte(name+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{name}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := os.MkdirAll("dir", 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+
+	stagedPaths := func() []string {
+		index, err := internal.ReadIndex(".")
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		var paths []string
+		for _, entry := range *index {
+			paths = append(paths, entry.Filepath)
+		}
+		return paths
+	}
+
+	// testing
+	t.Run("Renames the file and its index entry", func(t *testing.T) {
+		if err := Mv("a.txt", "renamed.txt"); err != nil {
+			t.Fatalf("Mv failed: %v", err)
+		}
+		if _, err := os.Stat("renamed.txt"); err != nil {
+			t.Errorf("renamed.txt does not exist: %v", err)
+		}
+		paths := stagedPaths()
+		if len(paths) != 2 || paths[0] != "b.txt" || paths[1] != "renamed.txt" {
+			t.Errorf("Expected b.txt and renamed.txt to be staged, got %v", paths)
+		}
+	})
+
+	t.Run("Moves into an existing directory", func(t *testing.T) {
+		if err := Mv("b.txt", "dir"); err != nil {
+			t.Fatalf("Mv failed: %v", err)
+		}
+		if _, err := os.Stat("dir/b.txt"); err != nil {
+			t.Errorf("dir/b.txt does not exist: %v", err)
+		}
+		paths := stagedPaths()
+		if len(paths) != 2 || paths[0] != "dir/b.txt" {
+			t.Errorf("Expected dir/b.txt to be staged, got %v", paths)
+		}
+	})
+
+	t.Run("Refuses to overwrite files", func(t *testing.T) {
+		if err := os.WriteFile("other.txt", []byte("other\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Mv("renamed.txt", "other.txt"); err == nil {
+			t.Errorf("Expected moving onto an existing file to fail")
+		}
+		if err := Mv("other.txt", "new.txt"); err == nil {
+			t.Errorf("Expected moving an unstaged file to fail")
+		}
+	})
+}
@@ -0,0 +1,20 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+)
+
+func Repack() error {
+	packed, err := internal.Repack(".")
+	if err != nil {
+		return fmt.Errorf("Failed to repack objects: %w", err)
+	}
+
+	if packed == 0 {
+		fmt.Println("Nothing to pack.")
+		return nil
+	}
+	fmt.Printf("Packed %d objects.\n", packed)
+	return nil
+}
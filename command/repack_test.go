@@ -0,0 +1,98 @@
+package command
+
+import (
+	"jit/config"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepack(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	// incompressible content so only deltas keep the pack small
+	content := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	var commits []string
+	for i := 0; i < 3; i++ {
+		content[i*1000] ^= 0xff
+		if err := os.WriteFile("data.bin", content, 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"data.bin"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit("version"); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		ref, err := os.ReadFile(filepath.Join(config.REPO_DIR, config.REFS_DIR, "heads", "master"))
+		if err != nil {
+			t.Fatalf("Failed to read master ref: %v", err)
+		}
+		commits = append(commits, strings.TrimSpace(string(ref)))
+	}
+
+	// testing
+	if err := Repack(); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+
+	objectsDir := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR)
+	entries, err := os.ReadDir(objectsDir)
+	if err != nil {
+		t.Fatalf("Failed to list objects: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "pack" {
+		t.Errorf("Expected only the pack directory to remain, got %v", entries)
+	}
+
+	packs, err := filepath.Glob(filepath.Join(objectsDir, "pack", "*.pack"))
+	if err != nil || len(packs) != 1 {
+		t.Fatalf("Expected a single pack, got %v (%v)", packs, err)
+	}
+	info, err := os.Stat(packs[0])
+	if err != nil {
+		t.Fatalf("Failed to stat pack: %v", err)
+	}
+	if info.Size() > int64(len(content))*3/2 {
+		t.Errorf("Pack is %d bytes, blobs were not delta compressed", info.Size())
+	}
+
+	t.Run("Objects are read from the pack", func(t *testing.T) {
+		if err := os.Remove("data.bin"); err != nil {
+			t.Fatalf("Failed to remove test file: %v", err)
+		}
+		if err := Clone(".", "clone"); err != nil {
+			t.Fatalf("Clone failed: %v", err)
+		}
+		cloned, err := os.ReadFile(filepath.Join("clone", "data.bin"))
+		if err != nil {
+			t.Fatalf("Failed to read cloned file: %v", err)
+		}
+		if string(cloned) != string(content) {
+			t.Errorf("Cloned file content does not match last version")
+		}
+		if err := Diff(commits[0], commits[2]); err != nil {
+			t.Errorf("Diff failed: %v", err)
+		}
+	})
+
+	t.Run("Repacking again keeps a single pack", func(t *testing.T) {
+		if err := Repack(); err != nil {
+			t.Fatalf("Repack failed: %v", err)
+		}
+		packs, _ := filepath.Glob(filepath.Join(objectsDir, "pack", "*.pack"))
+		if len(packs) != 1 {
+			t.Errorf("Expected a single pack, got %v", packs)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"jit/internal"
+)
+
+// Reset moves HEAD to the commit named by <args> in <mode>, HEAD itself
+// when no commit is given. Paths in <args> reset their index entries to HEAD
+func Reset(mode string, args []string) error {
+	if len(args) == 1 || len(args) == 0 {
+		rev := "HEAD"
+		if len(args) == 1 {
+			rev = args[0]
+		}
+		commitHash, err := internal.ResolveCommit(rev)
+		if err == nil {
+			return resetHEAD(commitHash, mode)
+		}
+		// only names that aren't revisions are taken as paths
+		if len(args) == 0 || mode != "" || !errors.Is(err, internal.ErrUnknownRevision) {
+			return err
+		}
+	}
+
+	if mode != "" {
+		return fmt.Errorf("%sCannot do a %s reset with paths.%s\nUsage: jit reset <path> ...",
+			colorRed, mode, colorNone)
+	}
+	unstaged, err := internal.ResetPaths(args)
+	if err != nil {
+		return err
+	}
+	for _, path := range unstaged {
+		fmt.Printf("Unstaged '%s'.\n", path)
+	}
+	return nil
+}
+
+func resetHEAD(commitHash, mode string) error {
+	if mode == "" {
+		mode = internal.ResetMixed
+	}
+	if err := internal.ResetHEAD(commitHash, mode); err != nil {
+		return err
+	}
+	commit, err := internal.LoadCommit(".", commitHash)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("HEAD is now at %s %s\n", commitHash, commit.Subject())
+	return nil
+}
@@ -0,0 +1,142 @@
+package command
+
+import (
+	"jit/config"
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReset(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.MkdirAll("dir/sub", 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	writeFile := func(name, content string) {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+	}
+	commit := func(message string) string {
+		if err := Add([]string{"."}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit(message); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		hash, err := internal.ResolveCommit("HEAD")
+		if err != nil {
+			t.Fatalf("ResolveCommit failed: %v", err)
+		}
+		return hash
+	}
+	status := func() *internal.Status {
+		status, err := internal.GetStatus()
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		return status
+	}
+
+	writeFile("top.txt", "top\n")
+	writeFile("dir/a.txt", "one\n")
+	first := commit("first")
+	writeFile("dir/a.txt", "two\n")
+	writeFile("dir/sub/b.txt", "b\n")
+	second := commit("second")
+
+	// testing
+	t.Run("Soft reset keeps the index and working directory", func(t *testing.T) {
+		if err := Reset(internal.ResetSoft, []string{"HEAD~1"}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		s := status()
+		if s.Head != first {
+			t.Errorf("Expected HEAD at %s, got %s", first, s.Head)
+		}
+		expected := []internal.FileChange{
+			{Path: "dir/a.txt", Change: internal.StatusModified},
+			{Path: "dir/sub/b.txt", Change: internal.StatusNew},
+		}
+		if !reflect.DeepEqual(s.Staged, expected) || len(s.Unstaged) != 0 {
+			t.Errorf("Expected staged changes %v only, got %+v", expected, s)
+		}
+	})
+
+	t.Run("Mixed reset rebuilds the index from nested trees", func(t *testing.T) {
+		if err := Reset(internal.ResetHard, []string{second}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		if err := Reset("", []string{"HEAD^"}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		s := status()
+		expectedUnstaged := []internal.FileChange{{Path: "dir/a.txt", Change: internal.StatusModified}}
+		if len(s.Staged) != 0 || !reflect.DeepEqual(s.Unstaged, expectedUnstaged) ||
+			!reflect.DeepEqual(s.Untracked, []string{"dir/sub/b.txt"}) {
+			t.Errorf("Unexpected status after mixed reset: %+v", s)
+		}
+	})
+
+	t.Run("Hard reset rewrites tracked files", func(t *testing.T) {
+		if err := Reset(internal.ResetHard, []string{second}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		if err := Reset(internal.ResetHard, []string{"HEAD~1"}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		if !status().Clean() {
+			t.Errorf("Expected a clean status, got %+v", status())
+		}
+		data, err := os.ReadFile("dir/a.txt")
+		if err != nil || string(data) != "one\n" {
+			t.Errorf("Expected dir/a.txt to be reset, got %q", data)
+		}
+		if _, err := os.Stat("dir/sub"); !os.IsNotExist(err) {
+			t.Errorf("Expected dir/sub to be removed")
+		}
+	})
+
+	t.Run("Path reset unstages a single file", func(t *testing.T) {
+		writeFile("dir/a.txt", "three\n")
+		writeFile("top.txt", "changed\n")
+		writeFile("new.txt", "new\n")
+		if err := Add([]string{"."}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Reset("", []string{"dir/a.txt", "new.txt"}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		s := status()
+		expected := []internal.FileChange{{Path: "top.txt", Change: internal.StatusModified}}
+		if !reflect.DeepEqual(s.Staged, expected) {
+			t.Errorf("Expected staged changes %v, got %v", expected, s.Staged)
+		}
+		if !reflect.DeepEqual(s.Untracked, []string{"new.txt"}) {
+			t.Errorf("Expected new.txt to be untracked, got %v", s.Untracked)
+		}
+		if s.Head != first {
+			t.Errorf("Expected HEAD to stay at %s, got %s", first, s.Head)
+		}
+	})
+
+	t.Run("Broken revisions are not taken as paths", func(t *testing.T) {
+		ref := filepath.Join(config.REPO_DIR, config.REFS_DIR, "heads", "broken")
+		if err := os.WriteFile(ref, []byte(strings.Repeat("0", len(first))+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write ref: %v", err)
+		}
+		err := Reset("", []string{"broken"})
+		if err == nil || !strings.Contains(err.Error(), "is not a commit") {
+			t.Errorf("Expected a broken branch error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+)
+
+// Restore discards the changes to <paths> in the working directory, or in
+// the staging area when <staged> is set, taking the files from the commit
+// named by <source>
+func Restore(paths []string, source string, staged bool) error {
+	if len(paths) < 1 {
+		return fmt.Errorf("%sNo file specified.%s\nUsage: jit restore [--source=<commit>] [--staged] <file1> <file2> ...",
+			colorRed, colorNone,
+		)
+	}
+
+	commitHash := ""
+	if source != "" {
+		var err error
+		if commitHash, err = internal.ResolveCommit(source); err != nil {
+			return err
+		}
+	}
+
+	restored, err := internal.RestoreFiles(paths, commitHash, staged)
+	if err != nil {
+		return err
+	}
+	for _, path := range restored {
+		if staged {
+			fmt.Printf("Unstaged '%s'.\n", path)
+		} else {
+			fmt.Printf("Restored '%s'.\n", path)
+		}
+	}
+	return nil
+}
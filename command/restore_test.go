@@ -0,0 +1,105 @@
+package command
+
+import (
+	"jit/internal"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.MkdirAll("dir", 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	writeFile := func(name, content string) {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+	}
+	readFile := func(name string) string {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Failed to read test file: %v", err)
+		}
+		return string(data)
+	}
+	commit := func(message string) {
+		if err := Add([]string{"."}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit(message); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+
+	writeFile("dir/a.txt", "one\n")
+	writeFile("b.txt", "one\n")
+	commit("first")
+	writeFile("dir/a.txt", "two\n")
+	commit("second")
+
+	// testing
+	t.Run("Working file is restored from the index", func(t *testing.T) {
+		writeFile("dir/a.txt", "staged\n")
+		if err := Add([]string{"dir/a.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		writeFile("dir/a.txt", "local edit\n")
+		writeFile("b.txt", "local edit\n")
+
+		if err := Restore([]string{"dir"}, "", false); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if got := readFile("dir/a.txt"); got != "staged\n" {
+			t.Errorf("Expected the staged content, got %q", got)
+		}
+		if got := readFile("b.txt"); got != "local edit\n" {
+			t.Errorf("Expected b.txt to keep its changes, got %q", got)
+		}
+	})
+
+	t.Run("Staged file is restored from HEAD", func(t *testing.T) {
+		if err := Restore([]string{"dir/a.txt"}, "", true); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		status, err := internal.GetStatus()
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		expected := []internal.FileChange{
+			{Path: "b.txt", Change: internal.StatusModified},
+			{Path: "dir/a.txt", Change: internal.StatusModified},
+		}
+		if len(status.Staged) != 0 || !reflect.DeepEqual(status.Unstaged, expected) {
+			t.Errorf("Expected unstaged changes %v only, got %+v", expected, status)
+		}
+		if got := readFile("dir/a.txt"); got != "staged\n" {
+			t.Errorf("Expected the working file to be left alone, got %q", got)
+		}
+	})
+
+	t.Run("Working file is restored from a commit", func(t *testing.T) {
+		if err := Restore([]string{"dir/a.txt", "b.txt"}, "HEAD~1", false); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if got := readFile("dir/a.txt"); got != "one\n" {
+			t.Errorf("Expected the content of the first commit, got %q", got)
+		}
+		if got := readFile("b.txt"); got != "one\n" {
+			t.Errorf("Expected the content of the first commit, got %q", got)
+		}
+	})
+
+	t.Run("Unknown paths fail", func(t *testing.T) {
+		if err := Restore([]string{"missing.txt"}, "", false); err == nil {
+			t.Errorf("Expected restoring an unknown path to fail")
+		}
+	})
+}
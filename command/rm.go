@@ -0,0 +1,23 @@
+package command
+
+import (
+	"fmt"
+	"jit/internal"
+)
+
+func Rm(paths []string, cached, force bool) error {
+	if len(paths) < 1 {
+		return fmt.Errorf("%sNo file specified.%s\nUsage: jit rm [--cached] [-f] <file1> <file2> ...",
+			colorRed, colorNone,
+		)
+	}
+
+	removed, err := internal.RemoveFromIndex(paths, cached, force)
+	if err != nil {
+		return err
+	}
+	for _, path := range removed {
+		fmt.Printf("rm '%s'\n", path)
+	}
+	return nil
+}
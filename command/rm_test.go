@@ -0,0 +1,124 @@
+package command
+
+import (
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRm(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.MkdirAll("dir", 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "dir/d.txt", "e.txt"} {
+		if err := os.WriteFile(name, []byte(name+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{name}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := Commit("first"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	staged := func() map[string]bool {
+		index, err := internal.ReadIndex(".")
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		paths := make(map[string]bool)
+		for _, entry := range *index {
+			paths[entry.Filepath] = true
+		}
+		return paths
+	}
+
+	// testing
+	t.Run("Removes the file and its index entry", func(t *testing.T) {
+		if err := Rm([]string{"a.txt"}, false, false); err != nil {
+			t.Fatalf("Rm failed: %v", err)
+		}
+		if staged()["a.txt"] {
+			t.Errorf("a.txt is still staged")
+		}
+		if _, err := os.Stat("a.txt"); !os.IsNotExist(err) {
+			t.Errorf("a.txt was not deleted")
+		}
+	})
+
+	t.Run("Cached keeps the file", func(t *testing.T) {
+		if err := Rm([]string{"b.txt"}, true, false); err != nil {
+			t.Fatalf("Rm failed: %v", err)
+		}
+		if staged()["b.txt"] {
+			t.Errorf("b.txt is still staged")
+		}
+		if _, err := os.Stat("b.txt"); err != nil {
+			t.Errorf("b.txt was deleted: %v", err)
+		}
+	})
+
+	t.Run("Refuses to remove modified files", func(t *testing.T) {
+		if err := os.WriteFile("c.txt", []byte("changed\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Rm([]string{"c.txt"}, false, false); err == nil {
+			t.Errorf("Expected removing a modified file to fail")
+		}
+		if !staged()["c.txt"] {
+			t.Errorf("c.txt was unstaged by a failed rm")
+		}
+		if err := Rm([]string{"c.txt"}, false, true); err != nil {
+			t.Errorf("Forced rm failed: %v", err)
+		}
+	})
+
+	t.Run("Refuses to remove files with a staged mode change", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no executable bit")
+		}
+		if err := os.Chmod("e.txt", 0755); err != nil {
+			t.Fatalf("Failed to chmod test file: %v", err)
+		}
+		if err := Add([]string{"e.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Rm([]string{"e.txt"}, false, false); err == nil {
+			t.Errorf("Expected removing a file with a staged mode change to fail")
+		}
+		if !staged()["e.txt"] {
+			t.Errorf("e.txt was unstaged by a failed rm")
+		}
+		if _, err := os.Stat("e.txt"); err != nil {
+			t.Errorf("e.txt was deleted: %v", err)
+		}
+	})
+
+	t.Run("Directories remove everything below them", func(t *testing.T) {
+		if err := Rm([]string{"dir"}, false, false); err != nil {
+			t.Fatalf("Rm failed: %v", err)
+		}
+		if staged()[filepath.ToSlash("dir/d.txt")] {
+			t.Errorf("dir/d.txt is still staged")
+		}
+		if _, err := os.Stat("dir"); !os.IsNotExist(err) {
+			t.Errorf("Empty directory was not removed")
+		}
+	})
+
+	t.Run("Unknown paths are an error", func(t *testing.T) {
+		if err := Rm([]string{"missing.txt"}, false, false); err == nil {
+			t.Errorf("Expected removing an unstaged path to fail")
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"jit/internal"
+	"os"
+	"sort"
+)
+
+// emptied by setupColor when color is off
+var colorGreen = "\033[0;32m"
+
+// porcelain codes of internal.Status changes
+var statusCodes = map[string]string{
+	internal.StatusNew:      "A",
+	internal.StatusModified: "M",
+	internal.StatusDeleted:  "D",
+}
+
+func Status(porcelain bool) error {
+	status, err := internal.GetStatus()
+	if err != nil {
+		return fmt.Errorf("Failed to get status: %w", err)
+	}
+	if porcelain {
+		writePorcelainStatus(os.Stdout, status)
+	} else {
+		writeStatus(os.Stdout, status)
+	}
+	return nil
+}
+
+// writeStatus prints <status> for humans
+func writeStatus(w io.Writer, status *internal.Status) {
+	if status.Branch != "" {
+		fmt.Fprintf(w, "On branch %s\n", status.Branch)
+	} else {
+		fmt.Fprintf(w, "HEAD detached at %s\n", status.Head)
+	}
+	if status.Head == "" {
+		fmt.Fprintln(w, "\nNo commits yet")
+	}
+
+	labels := map[string]string{
+		internal.StatusNew:      "new file:",
+		internal.StatusModified: "modified:",
+		internal.StatusDeleted:  "deleted: ",
+	}
+	if len(status.Staged) > 0 {
+		fmt.Fprintln(w, "\nChanges to be committed:")
+		for _, change := range status.Staged {
+			fmt.Fprintf(w, "\t%s%s   %s%s\n", colorGreen, labels[change.Change], change.Path, colorNone)
+		}
+	}
+	if len(status.Unstaged) > 0 {
+		fmt.Fprintln(w, "\nChanges not staged for commit:")
+		for _, change := range status.Unstaged {
+			fmt.Fprintf(w, "\t%s%s   %s%s\n", colorRed, labels[change.Change], change.Path, colorNone)
+		}
+	}
+	if len(status.Untracked) > 0 {
+		fmt.Fprintln(w, "\nUntracked files:")
+		for _, path := range status.Untracked {
+			fmt.Fprintf(w, "\t%s%s%s\n", colorRed, path, colorNone)
+		}
+	}
+
+	if status.Clean() {
+		fmt.Fprintln(w, "nothing to commit, working tree clean")
+	}
+}
+
+// writePorcelainStatus prints <status> in a stable format for scripts
+// ## <branch>
+// <staged code><unstaged code> <path>
+// ?? <untracked path>
+// codes are A (new), M (modified), D (deleted) or a space
+func writePorcelainStatus(w io.Writer, status *internal.Status) {
+	if status.Branch != "" {
+		fmt.Fprintf(w, "## %s\n", status.Branch)
+	} else {
+		fmt.Fprintln(w, "## HEAD (no branch)")
+	}
+
+	codes := make(map[string][2]string)
+	var paths []string
+	for i, changes := range [][]internal.FileChange{status.Staged, status.Unstaged} {
+		for _, change := range changes {
+			code, seen := codes[change.Path]
+			if !seen {
+				code = [2]string{" ", " "}
+				paths = append(paths, change.Path)
+			}
+			code[i] = statusCodes[change.Change]
+			codes[change.Path] = code
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(w, "%s%s %s\n", codes[path][0], codes[path][1], path)
+	}
+	for _, path := range status.Untracked {
+		fmt.Fprintf(w, "?? %s\n", path)
+	}
+}
@@ -0,0 +1,90 @@
+package command
+
+import (
+	"bytes"
+	"jit/internal"
+	"os"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	files := map[string]string{
+		"kept.txt":     "kept\n",
+		"modified.txt": "modified\n",
+		"deleted.txt":  "deleted\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{name}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := Commit("first"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	porcelain := func() string {
+		status, err := internal.GetStatus()
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		var out bytes.Buffer
+		writePorcelainStatus(&out, status)
+		return out.String()
+	}
+
+	// testing
+	t.Run("Clean working tree", func(t *testing.T) {
+		if got := porcelain(); got != "## master\n" {
+			t.Errorf("Expected a clean status, got:\n%s", got)
+		}
+	})
+
+	t.Run("Staged, unstaged and untracked changes", func(t *testing.T) {
+		if err := os.WriteFile("modified.txt", []byte("changed\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"modified.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := os.WriteFile("modified.txt", []byte("changed again\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := os.WriteFile("new.txt", []byte("new\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"new.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := os.Remove("deleted.txt"); err != nil {
+			t.Fatalf("Failed to remove test file: %v", err)
+		}
+		if err := os.WriteFile("untracked.txt", []byte("untracked\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := os.WriteFile(".jitignore", []byte("ignored.txt\n"), 0644); err != nil {
+			t.Fatalf("Failed to write .jitignore: %v", err)
+		}
+		if err := os.WriteFile("ignored.txt", []byte("ignored\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+
+		expected := "## master\n" +
+			" D deleted.txt\n" +
+			"MM modified.txt\n" +
+			"A  new.txt\n" +
+			"?? untracked.txt\n"
+		if got := porcelain(); got != expected {
+			t.Errorf("Status mismatch.\nExpected:\n%s\nGot:\n%s", expected, got)
+		}
+	})
+}
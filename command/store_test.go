@@ -0,0 +1,71 @@
+package command
+
+import (
+	"errors"
+	"jit/config"
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryObjectStore(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	store := internal.NewMemoryObjectStore()
+	internal.SetObjectStore(store)
+	defer internal.SetObjectStore(nil)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	// testing
+	if err := os.WriteFile("memory.txt", []byte("kept in memory\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := Add([]string{"memory.txt"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := Commit("in memory"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(config.REPO_DIR, config.OBJECTS_DIR))
+	if err != nil {
+		t.Fatalf("Failed to list objects directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no objects on disk, got %d entries", len(entries))
+	}
+
+	// blob, tree and commit
+	count := 0
+	err = store.Iterate(func(hash string) error {
+		if !store.Has(hash) {
+			t.Errorf("Iterated object %s is not in the store", hash)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 objects in memory, got %d", count)
+	}
+
+	if err := Log(); err != nil {
+		t.Errorf("Log failed: %v", err)
+	}
+
+	// packing would miss every object in memory
+	if err := Repack(); !errors.Is(err, internal.ErrNotOnDisk) {
+		t.Errorf("Expected repack to refuse the memory store, got %v", err)
+	}
+	if err := GC(false, "now"); !errors.Is(err, internal.ErrNotOnDisk) {
+		t.Errorf("Expected gc to refuse the memory store, got %v", err)
+	}
+}
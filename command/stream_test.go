@@ -0,0 +1,147 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"jit/internal"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// changingStore rewrites the file being added while its content is streamed
+type changingStore struct {
+	*internal.LooseObjectStore
+	path string
+}
+
+func (s *changingStore) PutStream(typ string, size int64, r io.Reader) (string, error) {
+	if err := os.WriteFile(s.path, bytes.Repeat([]byte("x"), int(size)), 0644); err != nil {
+		return "", err
+	}
+	return s.LooseObjectStore.PutStream(typ, size, r)
+}
+
+func TestStreamingLargeFiles(t *testing.T) {
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	// above the size packs store whole, random so it doesn't compress away
+	large := make([]byte, 17<<20)
+	rand.New(rand.NewSource(1)).Read(large)
+	if err := os.WriteFile("large.bin", large, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := Add([]string{"large.bin"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := Commit("large file"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	checkFile := func(path string) {
+		t.Helper()
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if !bytes.Equal(content, large) {
+			t.Errorf("Expected %s to hold the original %d bytes, got %d different bytes",
+				path, len(large), len(content))
+		}
+	}
+
+	// testing
+	t.Run("Large files survive checkout and clone", func(t *testing.T) {
+		if err := Branch("other"); err != nil {
+			t.Fatalf("Branch failed: %v", err)
+		}
+		if err := Checkout("other"); err != nil {
+			t.Fatalf("Checkout failed: %v", err)
+		}
+		if err := Rm([]string{"large.bin"}, false, false); err != nil {
+			t.Fatalf("Rm failed: %v", err)
+		}
+		if err := os.WriteFile("small.txt", []byte("small\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := Add([]string{"small.txt"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := Commit("without large file"); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if err := Checkout("master"); err != nil {
+			t.Fatalf("Checkout failed: %v", err)
+		}
+		checkFile("large.bin")
+
+		dst := filepath.Join(t.TempDir(), "clone")
+		if err := Clone(".", dst); err != nil {
+			t.Fatalf("Clone failed: %v", err)
+		}
+		checkFile(filepath.Join(dst, "large.bin"))
+	})
+
+	t.Run("Streamed packed objects read back the same", func(t *testing.T) {
+		if err := Repack(); err != nil {
+			t.Fatalf("Repack failed: %v", err)
+		}
+		hash := blobHash(large)
+		if _, err := os.Stat(objectFile(hash)); !os.IsNotExist(err) {
+			t.Fatalf("Expected the large blob to be packed")
+		}
+
+		typ, size, r, err := internal.NewLooseObjectStore(".").Open(hash)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("Failed to read packed object: %v", err)
+		}
+		if typ != "blob" || size != int64(len(large)) || !bytes.Equal(content, large) {
+			t.Errorf("Expected a %d byte blob, got a %d byte %s with different content",
+				len(large), size, typ)
+		}
+
+		if err := os.Remove("large.bin"); err != nil {
+			t.Fatalf("Failed to remove test file: %v", err)
+		}
+		if err := Restore([]string{"large.bin"}, "", false); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		checkFile("large.bin")
+	})
+
+	t.Run("Files changed while being added are rejected", func(t *testing.T) {
+		path := "changing.bin"
+		if err := os.WriteFile(path, large[:1<<20], 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		internal.SetObjectStore(&changingStore{internal.NewLooseObjectStore("."), path})
+		defer internal.SetObjectStore(nil)
+
+		err := Add([]string{path})
+		if err == nil || !strings.Contains(err.Error(), "changed while it was being added") {
+			t.Fatalf("Expected a changed file error, got %v", err)
+		}
+		index, err := internal.ReadIndex(".")
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		for _, entry := range *index {
+			if entry.Filepath == path {
+				t.Errorf("Expected %s not to be staged", path)
+			}
+		}
+	})
+}
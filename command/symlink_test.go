@@ -0,0 +1,114 @@
+package command
+
+import (
+	"jit/internal"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on windows")
+	}
+	// setup
+	currDir := SetupTempDirCd(t)
+	defer ChangeDirectory(currDir, t)
+
+	if err := Init([]string{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.MkdirAll("dir", 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.WriteFile("dir/target.txt", []byte("target\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	links := map[string]string{
+		"link":     "dir/target.txt",
+		"dirlink":  "dir",
+		"dangling": "missing.txt",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, name); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+	}
+	if err := Add([]string{"."}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := Commit("links"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// testing
+	t.Run("Symlinks are staged as their target", func(t *testing.T) {
+		index, err := internal.ReadIndex(".")
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		var paths []string
+		for _, entry := range *index {
+			paths = append(paths, entry.Filepath)
+			target, isLink := links[entry.Filepath]
+			if !isLink {
+				continue
+			}
+			if entry.Mode != 0o120000 {
+				t.Errorf("Expected %s to be staged as a symlink, got mode %o", entry.Filepath, entry.Mode)
+			}
+			if got := readBlob(t, entry.Hash); got != target {
+				t.Errorf("Expected %s to store '%s', got '%s'", entry.Filepath, target, got)
+			}
+		}
+		expected := []string{"dangling", "dir/target.txt", "dirlink", "link"}
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("Expected index %v, got %v", expected, paths)
+		}
+	})
+
+	t.Run("Retargeted symlinks show in status", func(t *testing.T) {
+		if err := os.Remove("link"); err != nil {
+			t.Fatalf("Failed to remove symlink: %v", err)
+		}
+		if err := os.Symlink("dir", "link"); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+		status, err := internal.GetStatus()
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		expected := []internal.FileChange{{Path: "link", Change: internal.StatusModified}}
+		if !reflect.DeepEqual(status.Unstaged, expected) || len(status.Untracked) != 0 {
+			t.Errorf("Expected unstaged changes %v only, got %+v", expected, status)
+		}
+	})
+
+	t.Run("Reset recreates symlinks", func(t *testing.T) {
+		if err := Reset(internal.ResetHard, []string{"HEAD"}); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		if target, err := os.Readlink("link"); err != nil || target != "dir/target.txt" {
+			t.Errorf("Expected link to point to dir/target.txt, got '%s' (%v)", target, err)
+		}
+		data, err := os.ReadFile("dir/target.txt")
+		if err != nil || string(data) != "target\n" {
+			t.Errorf("Expected the link target to be left alone, got %q", data)
+		}
+	})
+
+	t.Run("Clone recreates symlinks", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "clone")
+		if err := Clone(".", dst); err != nil {
+			t.Fatalf("Clone failed: %v", err)
+		}
+		for name, expected := range links {
+			target, err := os.Readlink(filepath.Join(dst, name))
+			if err != nil || target != expected {
+				t.Errorf("Expected %s to point to %s, got '%s' (%v)", name, expected, target, err)
+			}
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package command
 
 import (
+	"jit/config"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -30,3 +32,8 @@ func ChangeDirectory(prevDir string, t *testing.T) {
 		t.Fatalf("Failed to restore working directory: %v", err)
 	}
 }
+
+// Returns the path of <name> inside the repository directory
+func repoFile(name string) string {
+	return filepath.Join(config.REPO_DIR, name)
+}
@@ -5,4 +5,34 @@ var (
 	REFS_DIR    string = "refs"
 	OBJECTS_DIR string = "objects"
 	HEAD_PATH   string = "HEAD"
+	CONFIG_PATH string = "config"
 )
+
+// repository settings stored in .jit/config, core settings
+// describe the repository and are never read from other sources
+const (
+	HASH_ALGORITHM_KEY = "core.hashalgorithm"
+	// files at least this large are stored as deduplicated chunks
+	CHUNK_THRESHOLD_KEY = "core.chunkthreshold"
+)
+
+// settings read from the environment, .jit/config and the user config
+const (
+	// identity recorded as author and committer of new commits
+	USER_NAME_KEY  = "user.name"
+	USER_EMAIL_KEY = "user.email"
+	// branch and hash algorithm of new repositories
+	DEFAULT_BRANCH_KEY = "init.defaultbranch"
+	DEFAULT_HASH_KEY   = "init.hashalgorithm"
+	// auto, always or never
+	COLOR_KEY = "color.ui"
+	// alias.<name> = <command> [<args>] adds the command jit <name>
+	ALIAS_PREFIX = "alias."
+)
+
+// user config file, below $XDG_CONFIG_HOME or ~/.config
+const USER_CONFIG_PATH = "jit/config"
+
+// prefix of environment variables overriding settings,
+// JIT_CONFIG_USER_NAME overrides user.name
+const ENV_PREFIX = "JIT_CONFIG_"
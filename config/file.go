@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/fsutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values holds settings as dotted lowercase keys, e.g. core.hashalgorithm
+type Values map[string]string
+
+// ReadFile parses a config file with one "<key> = <value>" per line.
+// Blank lines and lines starting with # are ignored.
+// A missing file has no values
+func ReadFile(path string) (Values, error) {
+	values := Values{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return values, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: expected '<key> = <value>'", path, lineNo)
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// UserConfigFile returns the path of the config file
+// that applies to every repository of the user
+func UserConfigFile() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, filepath.FromSlash(USER_CONFIG_PATH)), nil
+}
+
+// CheckKey returns an error unless <key> is a valid
+// "<section>.<name>" key, keys are case insensitive
+func CheckKey(key string) error {
+	section, name, found := strings.Cut(key, ".")
+	if !found || section == "" || name == "" {
+		return fmt.Errorf("invalid key '%s', expected '<section>.<name>'", key)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_') {
+			return fmt.Errorf("invalid key '%s', only letters, digits, '.', '-' and '_' are allowed", key)
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable overriding <key>
+func EnvName(key string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// WriteFile writes <values> to <path> sorted by key,
+// replacing the file atomically so it is never half written
+func WriteFile(path string, values Values) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(fmt.Sprintf("%s = %s\n", key, values[key]))
+	}
+	return fsutil.WriteFileAtomic(path, []byte(sb.String()), 0644)
+}
+
+// ParseSize parses a byte count with an optional k, m or g suffix
+func ParseSize(value string) (int64, error) {
+	units := map[string]int64{"k": 1 << 10, "m": 1 << 20, "g": 1 << 30}
+	value = strings.ToLower(strings.TrimSpace(value))
+	unit := int64(1)
+	if len(value) > 0 {
+		if u, ok := units[value[len(value)-1:]]; ok {
+			unit = u
+			value = value[:len(value)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	return n * unit, nil
+}
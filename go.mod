@@ -4,7 +4,7 @@ go 1.22.0
 
 require (
 	github.com/mrk21/go-diff-fmt v0.2.0
-	github.com/sergi/go-diff v1.3.1
+	github.com/sergi/go-diff v1.4.0
 )
 
 require (
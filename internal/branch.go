@@ -3,6 +3,7 @@ package internal
 import (
 	"fmt"
 	"jit/config"
+	"jit/fsutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -21,11 +22,17 @@ func CreateBranch(name string) error {
 	}
 	branchRefPath := filepath.Join(config.REPO_DIR, config.REFS_DIR, "heads", name)
 
-	return os.WriteFile(branchRefPath, []byte(headCommitHash+"\n"), 0644)
+	return writeRef(branchRefPath, headCommitHash)
 }
 
-// ListBranches lists all the branches in the refs/heads
-func ListBranches() error {
+// IsBranchName reports whether <name> can name a branch
+func IsBranchName(name string) bool {
+	return name != "" && isRefName(name) && !strings.ContainsAny(name, `/\ `)
+}
+
+// ListBranches lists all the branches in the refs/heads,
+// the current one in green when <color> is set
+func ListBranches(color bool) error {
 	refsDir := filepath.Join(config.REPO_DIR, config.REFS_DIR, "heads")
 
 	files, err := os.ReadDir(refsDir)
@@ -37,14 +44,17 @@ func ListBranches() error {
 	if err != nil {
 		return fmt.Errorf("failed get current Branch: %w", err)
 	}
-	const (
-		colorGreen = "\033[32m"
-		colorReset = "\033[0m"
-	)
+	colorGreen, colorReset := "\033[32m", "\033[0m"
+	if !color {
+		colorGreen, colorReset = "", ""
+	}
 
 	fmt.Println("Branches:")
 	for _, file := range files {
 		branchName := file.Name()
+		if !isRefName(branchName) {
+			continue
+		}
 		if branchName == currBranch {
 			fmt.Printf("* %s%s%s\n", colorGreen, branchName, colorReset)
 		} else {
@@ -129,7 +139,11 @@ func CheckoutBranch(branchName string) error {
 		return fmt.Errorf("Error getting updated working dir index")
 	}
 
-	saveIndex(workingDirIndex)
+	// the index goes first, a crash before HEAD moves leaves the
+	// old branch checked out with the new files staged
+	if err := saveIndex(workingDirIndex); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
 
 	// update HEAD to point to new branch
 	err = changeHEAD(branchName)
@@ -143,6 +157,12 @@ func CheckoutBranch(branchName string) error {
 // changeHEAD updates HEAD pointer to point to branchName
 func changeHEAD(branchName string) error {
 	headPath := filepath.Join(config.REPO_DIR, config.HEAD_PATH)
-	return os.WriteFile(
+	lock, err := acquireLock(headPath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	return fsutil.WriteFileAtomic(
 		headPath, []byte(fmt.Sprintf("ref: refs/heads/%s\n", branchName)), 0644)
 }
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"container/list"
+	"path/filepath"
+	"sync"
+)
+
+// size of the encoded objects each parsed object cache may hold
+const objectCacheSize = 16 << 20
+
+var (
+	commitCache = newLRUCache[*Commit](objectCacheSize)
+	treeCache   = newLRUCache[*Tree](objectCacheSize)
+)
+
+// lruCache is a size-bounded least recently used cache, safe for
+// concurrent use. Values must not be modified once added, callers
+// hand out copies.
+type lruCache[V any] struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+	size  int64
+}
+
+func newLRUCache[V any](maxSize int64) *lruCache[V] {
+	return &lruCache[V]{
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the value cached under <key>
+func (c *lruCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry[V]).value, true
+}
+
+// add caches <value> of <size> bytes under <key>,
+// evicting the least recently used values to stay within bounds
+func (c *lruCache[V]) add(key string, value V, size int64) {
+	if size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry[V]{key, value, size})
+	c.size += size
+
+	for c.size > c.maxSize {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*lruEntry[V])
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= entry.size
+	}
+}
+
+// objectCacheKey returns the key of <hash> in the repository at <repoPath>,
+// an object cached for one repository may be missing from another
+func objectCacheKey(repoPath, hash string) string {
+	if absPath, err := filepath.Abs(repoPath); err == nil {
+		repoPath = absPath
+	}
+	return repoPath + "\x00" + hash
+}
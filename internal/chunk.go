@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"jit/config"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Large files can be stored as a chunked object listing the blobs their
+// content was split into, so versions that differ a little share most
+// of their chunks.
+// format, one line per chunk in file order
+// <blob hash> <size>
+//
+// Chunk boundaries are content-defined (FastCDC) so inserting or removing
+// bytes only changes the chunks around the edit.
+const (
+	chunkMinSize = 16 << 10
+	chunkAvgSize = 64 << 10
+	chunkMaxSize = 256 << 10
+)
+
+var (
+	// gear hash values per byte, part of the object format: changing
+	// them changes the id of every chunked file
+	chunkGear [256]uint64
+	// harder to match before the average chunk size, easier after it
+	chunkMaskSmall uint64
+	chunkMaskLarge uint64
+)
+
+func init() {
+	// splitmix64 with a fixed seed
+	seed := uint64(0x6a6974)
+	for i := range chunkGear {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		chunkGear[i] = z ^ (z >> 31)
+	}
+	chunkMaskSmall = spreadMask(18)
+	chunkMaskLarge = spreadMask(14)
+}
+
+// spreadMask returns a mask with <bits> bits set, spread over the upper
+// 48 bits so that matches depend on a wide window of input bytes
+func spreadMask(bits int) uint64 {
+	var mask uint64
+	step := 48 / bits
+	for i := 0; i < bits; i++ {
+		mask |= 1 << (63 - i*step)
+	}
+	return mask
+}
+
+// chunkCutPoint returns the length of the first chunk of <data>
+func chunkCutPoint(data []byte) int {
+	n := len(data)
+	if n <= chunkMinSize {
+		return n
+	}
+	if n > chunkMaxSize {
+		n = chunkMaxSize
+	}
+	normal := chunkAvgSize
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	i := chunkMinSize
+	for ; i < normal; i++ {
+		fp = (fp << 1) + chunkGear[data[i]]
+		if fp&chunkMaskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + chunkGear[data[i]]
+		if fp&chunkMaskLarge == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// chunker splits a stream into content-defined chunks,
+// holding at most chunkMaxSize bytes in memory
+type chunker struct {
+	r        io.Reader
+	buf      []byte
+	n        int
+	consumed int
+	eof      bool
+}
+
+func newChunker(r io.Reader) *chunker {
+	return &chunker{r: r, buf: make([]byte, chunkMaxSize)}
+}
+
+// next returns the next chunk, valid until the following call
+// Returns io.EOF after the last chunk
+func (c *chunker) next() ([]byte, error) {
+	if c.consumed > 0 {
+		copy(c.buf, c.buf[c.consumed:c.n])
+		c.n -= c.consumed
+		c.consumed = 0
+	}
+	for !c.eof && c.n < len(c.buf) {
+		m, err := c.r.Read(c.buf[c.n:])
+		c.n += m
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if c.n == 0 {
+		return nil, io.EOF
+	}
+
+	c.consumed = chunkCutPoint(c.buf[:c.n])
+	return c.buf[:c.consumed], nil
+}
+
+type chunkRef struct {
+	Hash string
+	Size int64
+}
+
+// parseChunkList parses the content of a chunked object
+func parseChunkList(data []byte) ([]chunkRef, error) {
+	var chunks []chunkRef
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		hash, sizeStr, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("malformed chunk entry: '%s'", line)
+		}
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("malformed chunk size: '%s'", line)
+		}
+		chunks = append(chunks, chunkRef{Hash: hash, Size: size})
+	}
+	return chunks, nil
+}
+
+// chunkThreshold returns the size from which files of the repository at
+// <repoPath> are chunked, 0 when chunking is disabled
+func chunkThreshold(repoPath string) int64 {
+	threshold, err := config.ParseSize(repoSetting(repoPath, config.CHUNK_THRESHOLD_KEY))
+	if err != nil {
+		return 0
+	}
+	return threshold
+}
+
+// SetChunkThreshold records in the config of the repository at <repoPath>
+// the size from which files are stored as chunks, "0" disables chunking.
+// Blob ids depend on the threshold, so it can only be changed while the
+// repository has no objects
+func SetChunkThreshold(repoPath, size string) error {
+	threshold, err := config.ParseSize(size)
+	if err != nil {
+		return err
+	}
+	if threshold != chunkThreshold(repoPath) {
+		if err := checkNoObjects(repoPath, config.CHUNK_THRESHOLD_KEY); err != nil {
+			return err
+		}
+	}
+	return setRepoSetting(repoPath, config.CHUNK_THRESHOLD_KEY, size)
+}
+
+// checkNoObjects returns an error naming <key> when the repository at
+// <repoPath> already stores objects
+func checkNoObjects(repoPath, key string) error {
+	errFound := errors.New("found")
+	err := objectStore(repoPath).Iterate(func(string) error { return errFound })
+	if errors.Is(err, errFound) {
+		return fmt.Errorf("%s can't be changed once the repository has objects", key)
+	}
+	return err
+}
+
+// shouldChunk reports whether a file of <size> bytes is stored as chunks
+func shouldChunk(repoPath string, size int64) bool {
+	threshold := chunkThreshold(repoPath)
+	return threshold > 0 && size >= threshold
+}
+
+// chunkFile splits the file at <path> into chunks and returns the id of
+// its chunked object. Chunks and the chunk list are only written to the
+// object store when <store> is set.
+func chunkFile(path string, store bool) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return chunkContent(f, store)
+}
+
+// chunkContent is chunkFile for the content read from <r>
+func chunkContent(r io.Reader, store bool) (string, error) {
+	var list bytes.Buffer
+	c := newChunker(r)
+	for {
+		chunk, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		var hash string
+		if store {
+			hash, err = writeObject(".", blobObject, chunk)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			hash = hashObject(blobObject, chunk)
+		}
+		fmt.Fprintf(&list, "%s %d\n", hash, len(chunk))
+	}
+
+	if store {
+		return writeObject(".", chunkedObject, list.Bytes())
+	}
+	return hashObject(chunkedObject, list.Bytes()), nil
+}
+
+// openBlob returns a reader over the file content stored under <hash>,
+// reassembling chunked objects on the fly
+func openBlob(repoPath, hash string) (io.ReadCloser, error) {
+	typ, _, r, err := openObject(repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case blobObject:
+		return r, nil
+	case chunkedObject:
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		chunks, err := parseChunkList(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse chunk list %s: %w", hash, err)
+		}
+		return &chunkedReader{repoPath: repoPath, chunks: chunks}, nil
+	default:
+		r.Close()
+		return nil, fmt.Errorf("object %s is a %s, not a %s", hash, typ, blobObject)
+	}
+}
+
+// blobChunks returns the chunks of the chunked object <hash>,
+// nil for plain blobs
+func blobChunks(repoPath, hash string) ([]chunkRef, error) {
+	typ, _, r, err := openObject(repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if typ != chunkedObject {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseChunkList(data)
+}
+
+// chunkedReader reads the chunks of a chunked object one after the other
+type chunkedReader struct {
+	repoPath string
+	chunks   []chunkRef
+	current  io.ReadCloser
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if len(c.chunks) == 0 {
+				return 0, io.EOF
+			}
+			chunk := c.chunks[0]
+			c.chunks = c.chunks[1:]
+			typ, size, r, err := openObject(c.repoPath, chunk.Hash)
+			if err != nil {
+				return 0, err
+			}
+			if typ != blobObject || size != chunk.Size {
+				r.Close()
+				return 0, fmt.Errorf("chunk %s does not match its chunk list entry", chunk.Hash)
+			}
+			c.current = r
+		}
+
+		n, err := c.current.Read(p)
+		if err == io.EOF {
+			c.current.Close()
+			c.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *chunkedReader) Close() error {
+	if c.current != nil {
+		return c.current.Close()
+	}
+	return nil
+}
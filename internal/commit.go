@@ -3,6 +3,7 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"jit/config"
 	"os"
@@ -21,6 +22,9 @@ type Commit struct {
 	Timestamp time.Time
 	TreeID    string
 	ParentIDs []string
+	// zero for commits made before identities were recorded
+	Author    Signature
+	Committer Signature
 }
 
 func (c *Commit) Serialize() []byte {
@@ -28,6 +32,8 @@ func (c *Commit) Serialize() []byte {
 	// tree <TreeID>
 	// parent <ParentID 1>
 	// parent <ParentID 2>...
+	// author <name> <<email>> <UNIX timestamp> <+hhmm>
+	// committer <name> <<email>> <UNIX timestamp> <+hhmm>
 	// timestamp <UNIX timestamps>
 	//
 	// <commit message>
@@ -37,6 +43,12 @@ func (c *Commit) Serialize() []byte {
 		sb.WriteString(fmt.Sprintf("parent %s\n", p))
 
 	}
+	if !c.Author.IsZero() {
+		sb.WriteString(fmt.Sprintf("author %s\n", c.Author))
+	}
+	if !c.Committer.IsZero() {
+		sb.WriteString(fmt.Sprintf("committer %s\n", c.Committer))
+	}
 
 	sb.WriteString(fmt.Sprintf("timestamp %d\n", c.Timestamp.Unix()))
 	sb.WriteString(fmt.Sprintf("\n%s\n", c.Message))
@@ -45,20 +57,23 @@ func (c *Commit) Serialize() []byte {
 }
 
 func (c *Commit) Save() (string, error) {
-	data := c.Serialize()
-	hash := ComputeHash(data)
+	return writeObject(".", commitObject, c.Serialize())
+}
 
-	err := os.WriteFile(
-		filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, hash), data, 0644,
-	)
-	if err != nil {
-		return "", err
-	}
-	return hash, nil
+// CommitOptions changes how CreateCommit builds a commit
+type CommitOptions struct {
+	// parents of the commit, nil for the HEAD commit if there is one
+	Parents []string
+	// author to keep, e.g. of an amended commit, nil for the current identity
+	Author *Signature
+	// commit HEAD must still point to when it is moved, "" for the HEAD
+	// commit read by CreateCommit
+	OldHead string
 }
 
-// CreateCommit creates a new commit with <message> and <timestamp>
-func CreateCommit(message string, timestamp time.Time, mergingParent *string) (string, error) {
+// CreateCommit creates a new commit of the staged files with <message> and
+// <timestamp> and moves HEAD to it
+func CreateCommit(message string, timestamp time.Time, opts CommitOptions) (string, error) {
 	stagedFiles, err := loadIndex()
 	if err != nil {
 		return "", err
@@ -76,34 +91,49 @@ func CreateCommit(message string, timestamp time.Time, mergingParent *string) (s
 		return "", err
 	}
 
+	var author Signature
+	if opts.Author != nil {
+		author = *opts.Author
+	} else if author, err = newSignature(roleAuthor, timestamp); err != nil {
+		return "", err
+	}
+	committer, err := newSignature(roleCommitter, timestamp)
+	if err != nil {
+		return "", err
+	}
+
 	commit := &Commit{
 		Message:   message,
 		Timestamp: timestamp,
 		TreeID:    tree.Hash,
 		ParentIDs: []string{},
+		Author:    author,
+		Committer: committer,
 	}
 
 	repoPath, err := os.Getwd()
 	if err != nil {
 		return "", fmt.Errorf("could not get current working directory: %w", err)
 	}
-	headCommit, _ := getHEADCommit(repoPath)
-	if headCommit != "" {
+	headCommit := opts.OldHead
+	if headCommit == "" {
+		if _, headCommit, err = resolveHEAD(repoPath); err != nil {
+			return "", fmt.Errorf("failed to read HEAD: %w", err)
+		}
+	}
+	if opts.Parents != nil {
+		commit.ParentIDs = append(commit.ParentIDs, opts.Parents...)
+	} else if headCommit != "" {
 		// first commit will not have any parents
 		commit.ParentIDs = append(commit.ParentIDs, headCommit)
 	}
 
-	if mergingParent != nil {
-		// merge commits have 2 parents
-		commit.ParentIDs = append(commit.ParentIDs, *mergingParent)
-	}
-
 	commitHash, err := commit.Save()
 	if err != nil {
 		return "", err
 	}
 
-	err = updateHEADCommitHash(commitHash)
+	err = updateHEADCommitHash(headCommit, commitHash)
 	if err != nil {
 		return "", err
 	}
@@ -111,22 +141,77 @@ func CreateCommit(message string, timestamp time.Time, mergingParent *string) (s
 	return commitHash, nil
 }
 
-var c *Commit = nil
+// AmendCommit replaces the HEAD commit with a commit of the staged files
+// that has the same parents and author. The message is kept when <message>
+// is empty
+func AmendCommit(message string, timestamp time.Time) (string, error) {
+	_, head, err := resolveHEAD(".")
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if head == "" {
+		return "", errors.New("there is no commit to amend")
+	}
+	previous, err := LoadCommit(".", head)
+	if err != nil {
+		return "", fmt.Errorf("failed to load commit %s: %w", head, err)
+	}
+
+	if message == "" {
+		message = previous.Message
+	}
+	opts := CommitOptions{
+		Parents: append([]string{}, previous.ParentIDs...),
+		// a commit made since HEAD was read fails the update instead of
+		// being dropped
+		OldHead: head,
+	}
+	if !previous.Author.IsZero() {
+		// commits made before identities were recorded get the current one
+		opts.Author = &previous.Author
+	}
+	return CreateCommit(message, timestamp, opts)
+}
 
 // LoadCommit returns the commit with the given <commitHash>
-// caches because commits are immutable
+// caches because commits are immutable, callers get their own copy
 func LoadCommit(repoPath, commitHash string) (*Commit, error) {
-	if c != nil && c.Hash == commitHash {
-		return c, nil
+	key := objectCacheKey(repoPath, commitHash)
+	if cached, ok := commitCache.get(key); ok {
+		return cached.clone(), nil
 	}
-	c = &Commit{}
 
-	data, err := os.ReadFile(filepath.Join(repoPath,
-		config.REPO_DIR, config.OBJECTS_DIR, commitHash))
+	data, err := readObjectOfType(repoPath, commitHash, commitObject)
 	if err != nil {
 		return nil, err
 	}
 
+	commit, err := parseCommit(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse commit %s: %w", commitHash, err)
+	}
+	commit.Hash = commitHash
+	commitCache.add(key, commit, int64(len(data)))
+
+	return commit.clone(), nil
+}
+
+// Subject returns the first line of the commit message
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+// clone returns a deep copy of the commit
+func (c *Commit) clone() *Commit {
+	copied := *c
+	copied.ParentIDs = append([]string{}, c.ParentIDs...)
+	return &copied
+}
+
+// parseCommit parses a serialized commit, see Commit.Serialize for the format
+func parseCommit(data []byte) (*Commit, error) {
+	c := &Commit{}
 	lines := strings.Split(string(data), "\n")
 	var i int
 	for ; i < len(lines); i++ {
@@ -141,6 +226,18 @@ func LoadCommit(repoPath, commitHash string) (*Commit, error) {
 		case strings.HasPrefix(line, "parent"):
 			parentID := strings.TrimSpace(strings.TrimPrefix(line, "parent"))
 			c.ParentIDs = append(c.ParentIDs, parentID)
+		case strings.HasPrefix(line, "author "):
+			author, err := parseSignature(strings.TrimPrefix(line, "author "))
+			if err != nil {
+				return nil, err
+			}
+			c.Author = author
+		case strings.HasPrefix(line, "committer "):
+			committer, err := parseSignature(strings.TrimPrefix(line, "committer "))
+			if err != nil {
+				return nil, err
+			}
+			c.Committer = committer
 		case strings.HasPrefix(line, "timestamp"):
 			timestamp := strings.TrimSpace(strings.TrimPrefix(line, "timestamp"))
 			unixTime, err := strconv.ParseInt(timestamp, 10, 64)
@@ -151,11 +248,18 @@ func LoadCommit(repoPath, commitHash string) (*Commit, error) {
 		}
 	}
 	if i < len(lines) {
-		c.Message = strings.TrimSpace(lines[i])
+		// only blank lines are dropped, indentation is part of the message
+		message := lines[i:]
+		for len(message) > 0 && strings.TrimSpace(message[0]) == "" {
+			message = message[1:]
+		}
+		for len(message) > 0 && strings.TrimSpace(message[len(message)-1]) == "" {
+			message = message[:len(message)-1]
+		}
+		c.Message = strings.Join(message, "\n")
 	}
-	c.Hash = commitHash
 
-	return c, err
+	return c, nil
 }
 
 func GetCommitHistory() ([]Commit, error) {
@@ -175,10 +279,10 @@ func getCommitHistoryFromHash(commitHash string) ([]Commit, error) {
 	for len(commitHash) > 0 {
 
 		commit, err := LoadCommit(".", commitHash)
-
-		if err == nil {
-			commits = append(commits, *commit)
+		if err != nil {
+			return nil, err
 		}
+		commits = append(commits, *commit)
 
 		if len(commit.ParentIDs) > 0 {
 			commitHash = commit.ParentIDs[0]
@@ -233,38 +337,41 @@ func DiffCommits(hash1, hash2 string) (map[string]string, error) {
 	}
 
 	for path := range allPaths {
-		hashA, inA := filesA[path]
-		hashB, inB := filesB[path]
+		entryA, inA := filesA[path]
+		entryB, inB := filesB[path]
 
 		switch {
 		case inA && !inB:
 			// file was removed
-			oldContent, err := loadBlobContent(hashA)
+			oldContent, err := loadBlobContent(entryA.Hash)
 			if err != nil {
 				return nil, err
 			}
 			diff[path] = generateUnifiedDiff(path, oldContent, "")
 		case !inA && inB:
 			// file was added
-			newContent, err := loadBlobContent(hashB)
+			newContent, err := loadBlobContent(entryB.Hash)
 			if err != nil {
 				return nil, err
 			}
 			diff[path] = generateUnifiedDiff(path, "", newContent)
-		case inA && inB && hashA != hashB:
+		case inA && inB && entryA.Hash != entryB.Hash:
 			// file modified
-			oldContent, err := loadBlobContent(hashA)
+			oldContent, err := loadBlobContent(entryA.Hash)
 			if err != nil {
 				return nil, err
 			}
-			newContent, err := loadBlobContent(hashB)
+			newContent, err := loadBlobContent(entryB.Hash)
 			if err != nil {
 				return nil, err
 			}
 			d := generateUnifiedDiff(path, oldContent, newContent)
 			if d != "" {
-				diff[path] = d
+				diff[path] = modeChange(entryA.Mode, entryB.Mode) + d
 			}
+		case inA && inB && entryA.Mode != entryB.Mode:
+			// only the mode changed
+			diff[path] = modeChange(entryA.Mode, entryB.Mode)
 		default:
 			// no change
 		}
@@ -273,48 +380,44 @@ func DiffCommits(hash1, hash2 string) (map[string]string, error) {
 	return diff, nil
 }
 
-// buildFileMapFromTree returns a map of filepath -> blobHash for all files
+// modeChange describes a change of file mode from <oldMode> to <newMode>,
+// "" if the mode didn't change
+func modeChange(oldMode, newMode uint32) string {
+	if oldMode == newMode {
+		return ""
+	}
+	return fmt.Sprintf("old mode %s\nnew mode %s\n", formatMode(oldMode), formatMode(newMode))
+}
+
+// buildFileMapFromTree returns a map of filepath -> blob entry for all files
 // under the given tree
-func buildFileMapFromTree(treeHash string) (map[string]string, error) {
-	result := make(map[string]string)
+func buildFileMapFromTree(treeHash string) (map[string]TreeEntry, error) {
+	result := make(map[string]TreeEntry)
 	err := walkTree("", treeHash, result)
 	return result, err
 }
 
 // walkTree recursively reads the tree object and populates 'result' with
-// filepath -> blobHash
-func walkTree(prefix, treeHash string, result map[string]string) error {
-	treePath := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, treeHash)
-	data, err := os.ReadFile(treePath)
+// filepath -> blob entry, entry names are the file paths
+func walkTree(prefix, treeHash string, result map[string]TreeEntry) error {
+	tree, err := loadTree(treeHash)
 	if err != nil {
 		return fmt.Errorf("failed to read tree object %s: %w", treeHash, err)
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.SplitN(line, " ", 3)
-		if len(parts) != 3 {
-			return fmt.Errorf("malformed tree entry line: %s", line)
-		}
-
-		typ := parts[0]
-		name := parts[1]
-		hash := parts[2]
+	for _, entry := range tree.Entries {
+		fullPath := filepath.Join(prefix, entry.Name)
 
-		fullPath := filepath.Join(prefix, name)
-
-		switch typ {
-		case "tree":
-			if err := walkTree(fullPath, hash, result); err != nil {
+		switch entry.Type {
+		case treeObject:
+			if err := walkTree(fullPath, entry.Hash, result); err != nil {
 				return err
 			}
-		case "blob":
-			result[fullPath] = hash
+		case blobObject:
+			entry.Name = fullPath
+			result[fullPath] = entry
 		default:
-			return fmt.Errorf("unknown type %s in tree %s", typ, treeHash)
+			return fmt.Errorf("unknown type %s in tree %s", entry.Type, treeHash)
 		}
 	}
 	return nil
@@ -322,25 +425,33 @@ func walkTree(prefix, treeHash string, result map[string]string) error {
 
 // loadBlobContent reads blob content from object store
 func loadBlobContent(blobHash string) (string, error) {
-	blobPath := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, blobHash)
-	data, err := os.ReadFile(blobPath)
+	r, err := openBlob(".", blobHash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob %s: %w", blobHash, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return "", fmt.Errorf("failed to read blob %s: %w", blobHash, err)
 	}
 	return string(data), nil
-	// return blobPath, nil
 }
 
-func generateUnifiedDiff(filename, oldContentPath, newContentPath string) string {
+// lineDiffs returns the line by line differences between
+// <oldContent> and <newContent>
+func lineDiffs(oldContent, newContent string) []difffmt.LineDiff {
 	// compute line mode diffing
 	dmp := diffmatchpatch.New()
-	runes1, runes2, lineArray := dmp.DiffLinesToRunes(oldContentPath, newContentPath)
+	runes1, runes2, lineArray := dmp.DiffLinesToRunes(oldContent, newContent)
 	diffs := dmp.DiffMainRunes(runes1, runes2, false)
 	diffs = dmp.DiffCharsToLines(diffs, lineArray)
 
+	return difffmt.MakeLineDiffsFromDMP(diffs)
+}
+
+func generateUnifiedDiff(filename, oldContentPath, newContentPath string) string {
 	// format []diffmatchpatch.Diff to Unified format
-	lineDiffs := difffmt.MakeLineDiffsFromDMP(diffs)
-	hunks := difffmt.MakeHunks(lineDiffs, 3)
+	hunks := difffmt.MakeHunks(lineDiffs(oldContentPath, newContentPath), 3)
 	unifiedFmt := difffmt.NewUnifiedFormat(difffmt.UnifiedFormatOption{
 		ColorMode: difffmt.ColorTerminalOnly,
 	})
@@ -406,31 +517,31 @@ func diffCommits(hash1, hash2 string) (map[string][]diffmatchpatch.Diff, error)
 	}
 
 	for path := range allPaths {
-		hashA, inA := filesA[path]
-		hashB, inB := filesB[path]
+		entryA, inA := filesA[path]
+		entryB, inB := filesB[path]
 
 		switch {
 		case inA && !inB:
 			// file was removed
-			oldContent, err := loadBlobContent(hashA)
+			oldContent, err := loadBlobContent(entryA.Hash)
 			if err != nil {
 				return nil, err
 			}
 			diff[path] = generateDiff(oldContent, "")
 		case !inA && inB:
 			// file was added
-			newContent, err := loadBlobContent(hashB)
+			newContent, err := loadBlobContent(entryB.Hash)
 			if err != nil {
 				return nil, err
 			}
 			diff[path] = generateDiff("", newContent)
-		case inA && inB && hashA != hashB:
+		case inA && inB && entryA.Hash != entryB.Hash:
 			// file modified
-			oldContent, err := loadBlobContent(hashA)
+			oldContent, err := loadBlobContent(entryA.Hash)
 			if err != nil {
 				return nil, err
 			}
-			newContent, err := loadBlobContent(hashB)
+			newContent, err := loadBlobContent(entryB.Hash)
 			if err != nil {
 				return nil, err
 			}
@@ -484,12 +595,12 @@ func getHEADCommit(repoPath string) (string, error) {
 			repoPath, config.REPO_DIR,
 			strings.TrimSpace(strings.TrimPrefix(refPath, "ref:")),
 		)
-		// we read the file master to get latest commit
+		// we read the branch file to get latest commit
 		hash, err := os.ReadFile(refPath)
 		if err != nil {
-			// we cannot create a branch if master does not exist
+			// we cannot create a branch if the current one does not exist
 			if errors.Is(err, fs.ErrNotExist) {
-				return "", fmt.Errorf("fatal: no valid object named 'master'")
+				return "", fmt.Errorf("fatal: no valid object named '%s'", filepath.Base(refPath))
 			}
 			return "", err
 		}
@@ -499,8 +610,9 @@ func getHEADCommit(repoPath string) (string, error) {
 	return refPath, nil
 }
 
-// updateHEAD changes HEAD to point to <commitHash>
-func updateHEADCommitHash(commitHash string) error {
+// updateHEADCommitHash moves the branch HEAD points to, or a detached
+// HEAD, from <oldHash> to <newHash>. Fails if another process moved it first
+func updateHEADCommitHash(oldHash, newHash string) error {
 	headContent, err := os.ReadFile(
 		filepath.Join(config.REPO_DIR, config.HEAD_PATH),
 	)
@@ -512,12 +624,10 @@ func updateHEADCommitHash(commitHash string) error {
 	if strings.HasPrefix(refLine, "ref:") {
 		refRelPath := strings.TrimSpace(strings.TrimPrefix(refLine, "ref:"))
 		refFilepath := filepath.Join(config.REPO_DIR, refRelPath)
-		return os.WriteFile(refFilepath, []byte(commitHash+"\n"), 0644)
+		return updateRef(refFilepath, oldHash, newHash)
 	} else {
 		// for jit checkout <commithash>, HEAD -> commit (not implemented)
-		return os.WriteFile(
-			filepath.Join(config.REPO_DIR, config.HEAD_PATH),
-			[]byte(commitHash), 0644,
-		)
+		return updateRef(
+			filepath.Join(config.REPO_DIR, config.HEAD_PATH), oldHash, newHash)
 	}
 }
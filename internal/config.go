@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// config scopes, from lowest to highest precedence
+const (
+	ConfigUser = "user"
+	ConfigRepo = "repo"
+	ConfigEnv  = "env"
+)
+
+// ConfigEntry is a setting and the scope it comes from
+type ConfigEntry struct {
+	Key   string
+	Value string
+	Scope string
+}
+
+// GetConfig returns the value of <key> for the current repository
+// <ok> is false when it is unset
+func GetConfig(key string) (value string, ok bool, err error) {
+	if err := config.CheckKey(key); err != nil {
+		return "", false, err
+	}
+	key = strings.ToLower(key)
+	if isCoreKey(key) {
+		value = repoSetting(".", key)
+	} else {
+		value = setting(".", key)
+	}
+	return value, value != "", nil
+}
+
+// SetConfig sets <key> to <value> in the config of <scope>
+func SetConfig(scope, key, value string) error {
+	if err := checkConfigChange(scope, key); err != nil {
+		return err
+	}
+	key = strings.ToLower(key)
+	if err := checkConfigValue(key, value); err != nil {
+		return err
+	}
+
+	if scope == ConfigRepo {
+		switch key {
+		case config.HASH_ALGORITHM_KEY:
+			return fmt.Errorf("%s can't be changed after the repository is created", key)
+		case config.CHUNK_THRESHOLD_KEY:
+			return SetChunkThreshold(".", value)
+		}
+		return setRepoSetting(".", key, value)
+	}
+
+	path, err := config.UserConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to find the user config: %w", err)
+	}
+	values, err := config.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values[key] = value
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return config.WriteFile(path, values)
+}
+
+// UnsetConfig removes <key> from the config of <scope>
+func UnsetConfig(scope, key string) error {
+	if err := checkConfigChange(scope, key); err != nil {
+		return err
+	}
+	key = strings.ToLower(key)
+
+	if scope == ConfigRepo {
+		if key == config.HASH_ALGORITHM_KEY {
+			return fmt.Errorf("%s can't be changed after the repository is created", key)
+		}
+		if repoSetting(".", key) == "" {
+			return fmt.Errorf("'%s' is not set in the repository config", key)
+		}
+		if key == config.CHUNK_THRESHOLD_KEY && chunkThreshold(".") != 0 {
+			if err := checkNoObjects(".", key); err != nil {
+				return err
+			}
+		}
+		return unsetRepoSetting(".", key)
+	}
+
+	path, err := config.UserConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to find the user config: %w", err)
+	}
+	values, err := config.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := values[key]; !ok {
+		return fmt.Errorf("'%s' is not set in the user config", key)
+	}
+	delete(values, key)
+	return config.WriteFile(path, values)
+}
+
+// ListConfig returns the settings in effect for the current repository
+// sorted by key, core settings only come from the repository config
+func ListConfig() ([]ConfigEntry, error) {
+	entries := make(map[string]ConfigEntry)
+	add := func(values config.Values, scope string) {
+		for key, value := range values {
+			if isCoreKey(key) && scope != ConfigRepo {
+				continue
+			}
+			entries[key] = ConfigEntry{key, value, scope}
+		}
+	}
+
+	path, err := config.UserConfigFile()
+	if err == nil {
+		values, err := config.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the user config: %w", err)
+		}
+		add(values, ConfigUser)
+	}
+	if isRepo() {
+		values, err := config.ReadFile(filepath.Join(config.REPO_DIR, config.CONFIG_PATH))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the repository config: %w", err)
+		}
+		add(values, ConfigRepo)
+	}
+	add(envSettings(), ConfigEnv)
+
+	list := make([]ConfigEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list, nil
+}
+
+// checkConfigChange returns an error unless <key> can be written to <scope>
+func checkConfigChange(scope, key string) error {
+	if err := config.CheckKey(key); err != nil {
+		return err
+	}
+	switch scope {
+	case ConfigUser:
+		if isCoreKey(key) {
+			return fmt.Errorf("%s can only be set in the repository config", strings.ToLower(key))
+		}
+	case ConfigRepo:
+		if !isRepo() {
+			return errors.New("not a jit repository")
+		}
+	default:
+		return fmt.Errorf("can't write to the %s config", scope)
+	}
+	return nil
+}
+
+// checkConfigValue returns an error unless <value> is valid for <key>,
+// a bad value of some settings would make every command fail
+func checkConfigValue(key, value string) error {
+	if value == "" || strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("invalid value '%s'", value)
+	}
+	switch key {
+	case config.COLOR_KEY:
+		if !IsColorSetting(value) {
+			return fmt.Errorf("invalid %s '%s', expected auto, always or never", key, value)
+		}
+	case config.DEFAULT_HASH_KEY:
+		if !IsHashAlgorithm(value) {
+			return fmt.Errorf("unsupported hash algorithm '%s'", value)
+		}
+	case config.DEFAULT_BRANCH_KEY:
+		if !IsBranchName(value) {
+			return fmt.Errorf("invalid branch name '%s'", value)
+		}
+	}
+	return nil
+}
+
+// IsColorSetting reports whether <value> is a valid color.ui
+func IsColorSetting(value string) bool {
+	switch strings.ToLower(value) {
+	case "auto", "always", "never", "true", "false":
+		return true
+	}
+	return false
+}
+
+// isCoreKey reports whether <key> describes the repository itself
+func isCoreKey(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), "core.")
+}
+
+// isRepo reports whether the current directory is a repository
+func isRepo() bool {
+	_, err := os.Stat(config.REPO_DIR)
+	return !errors.Is(err, fs.ErrNotExist)
+}
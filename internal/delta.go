@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Deltas describe a target object in terms of a base object.
+// format
+// <base size uvarint> <target size uvarint> <instructions>...
+//
+// instructions
+// 0x80 | flags, offset and size bytes: copy bytes from the base.
+//
+//	flag bits 0-3 select which little endian offset bytes follow,
+//	flag bits 4-6 select which size bytes follow, size 0 means 0x10000
+//
+// 0x01 - 0x7f: insert the next n bytes literally
+const (
+	deltaBlockSize  = 16
+	deltaMaxInsert  = 0x7f
+	deltaMaxCopy    = 0xffffff
+	deltaCopyOpcode = 0x80
+)
+
+// createDelta returns a delta that turns <base> into <target>
+func createDelta(base, target []byte) []byte {
+	var out bytes.Buffer
+	writeUvarint(&out, uint64(len(base)))
+	writeUvarint(&out, uint64(len(target)))
+
+	// offsets of every full block in base, first occurrence wins
+	blocks := make(map[string]int, len(base)/deltaBlockSize)
+	for off := 0; off+deltaBlockSize <= len(base); off += deltaBlockSize {
+		key := string(base[off : off+deltaBlockSize])
+		if _, exists := blocks[key]; !exists {
+			blocks[key] = off
+		}
+	}
+
+	var insert []byte
+	i := 0
+	for i+deltaBlockSize <= len(target) {
+		off, found := blocks[string(target[i:i+deltaBlockSize])]
+		if !found {
+			insert = append(insert, target[i])
+			i++
+			continue
+		}
+
+		// extend the match forwards
+		n := deltaBlockSize
+		for off+n < len(base) && i+n < len(target) && base[off+n] == target[i+n] {
+			n++
+		}
+		i += n
+
+		// and backwards into pending literal bytes
+		for len(insert) > 0 && off > 0 && base[off-1] == insert[len(insert)-1] {
+			insert = insert[:len(insert)-1]
+			off--
+			n++
+		}
+
+		writeDeltaInsert(&out, insert)
+		insert = insert[:0]
+		writeDeltaCopy(&out, off, n)
+	}
+	insert = append(insert, target[i:]...)
+	writeDeltaInsert(&out, insert)
+
+	return out.Bytes()
+}
+
+// applyDelta rebuilds the target object from <base> and <delta>
+func applyDelta(base, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	baseSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("malformed delta header: %w", err)
+	}
+	targetSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("malformed delta header: %w", err)
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf(
+			"delta base size mismatch: expected %d, got %d", baseSize, len(base))
+	}
+
+	target := make([]byte, 0, targetSize)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		switch {
+		case op&deltaCopyOpcode != 0:
+			var off, size int
+			for bit := 0; bit < 4; bit++ {
+				if op&(1<<bit) != 0 {
+					b, err := r.ReadByte()
+					if err != nil {
+						return nil, errors.New("truncated delta copy offset")
+					}
+					off |= int(b) << (8 * bit)
+				}
+			}
+			for bit := 0; bit < 3; bit++ {
+				if op&(1<<(4+bit)) != 0 {
+					b, err := r.ReadByte()
+					if err != nil {
+						return nil, errors.New("truncated delta copy size")
+					}
+					size |= int(b) << (8 * bit)
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if off+size > len(base) {
+				return nil, errors.New("delta copy out of base bounds")
+			}
+			target = append(target, base[off:off+size]...)
+		case op != 0:
+			literal := make([]byte, op)
+			if _, err := io.ReadFull(r, literal); err != nil {
+				return nil, errors.New("truncated delta insert")
+			}
+			target = append(target, literal...)
+		default:
+			return nil, errors.New("invalid delta opcode 0")
+		}
+	}
+
+	if uint64(len(target)) != targetSize {
+		return nil, fmt.Errorf(
+			"delta target size mismatch: expected %d, got %d", targetSize, len(target))
+	}
+	return target, nil
+}
+
+func writeDeltaInsert(out *bytes.Buffer, literal []byte) {
+	for len(literal) > 0 {
+		n := len(literal)
+		if n > deltaMaxInsert {
+			n = deltaMaxInsert
+		}
+		out.WriteByte(byte(n))
+		out.Write(literal[:n])
+		literal = literal[n:]
+	}
+}
+
+func writeDeltaCopy(out *bytes.Buffer, off, size int) {
+	for size > 0 {
+		n := size
+		if n > deltaMaxCopy {
+			n = deltaMaxCopy
+		}
+
+		op := byte(deltaCopyOpcode)
+		var args []byte
+		for bit := 0; bit < 4; bit++ {
+			if b := byte(off >> (8 * bit)); b != 0 {
+				op |= 1 << bit
+				args = append(args, b)
+			}
+		}
+		for bit := 0; bit < 3; bit++ {
+			if b := byte(n >> (8 * bit)); b != 0 {
+				op |= 1 << (4 + bit)
+				args = append(args, b)
+			}
+		}
+		out.WriteByte(op)
+		out.Write(args)
+
+		off += n
+		size -= n
+	}
+}
+
+func writeUvarint(out *bytes.Buffer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	out.Write(buf[:n])
+}
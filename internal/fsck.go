@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kinds of problems found by CheckObjects
+const (
+	FsckCorrupt  = "corrupt"
+	FsckMissing  = "missing"
+	FsckDangling = "dangling"
+)
+
+type FsckProblem struct {
+	Kind   string // corrupt, missing or dangling
+	Type   string // object type, "unknown" if it can't be read
+	Hash   string
+	Reason string // why a corrupt object is corrupt
+}
+
+// String formats the problem as a single machine-readable line
+// <kind> <type> <hash> [<reason>]
+func (p FsckProblem) String() string {
+	if p.Reason == "" {
+		return fmt.Sprintf("%s %s %s", p.Kind, p.Type, p.Hash)
+	}
+	return fmt.Sprintf("%s %s %s %s", p.Kind, p.Type, p.Hash, p.Reason)
+}
+
+// objectRef is a reference from one object to another
+type objectRef struct {
+	Hash string
+	Type string
+}
+
+// CheckObjects verifies every object in the current repository:
+// it rehashes their content, validates commit and tree syntax,
+// and looks for references to missing objects and for dangling
+// objects nothing points to.
+// Returns the problems found sorted by hash
+func CheckObjects() ([]FsckProblem, error) {
+	store := objectStore(".")
+
+	var problems []FsckProblem
+	present := make(map[string]bool)
+	types := make(map[string]string)
+	refs := make(map[string][]objectRef)
+	err := store.Iterate(func(hash string) error {
+		present[hash] = true
+		typ, data, err := store.Get(hash)
+		if err != nil {
+			problems = append(problems,
+				FsckProblem{FsckCorrupt, "unknown", hash, unwrapReason(err)})
+			return nil
+		}
+		types[hash] = typ
+
+		if actual := hashObject(typ, data); actual != hash {
+			problems = append(problems, FsckProblem{FsckCorrupt, typ, hash,
+				fmt.Sprintf("content hashes to %s", actual)})
+			return nil
+		}
+
+		var objRefs []objectRef
+		switch typ {
+		case commitObject:
+			objRefs, err = validateCommit(data)
+		case treeObject:
+			objRefs, err = validateTree(data)
+		case chunkedObject:
+			objRefs, err = validateChunkList(data)
+		case blobObject:
+		default:
+			err = fmt.Errorf("unknown object type '%s'", typ)
+		}
+		if err != nil {
+			problems = append(problems, FsckProblem{FsckCorrupt, typ, hash, err.Error()})
+			return nil
+		}
+		refs[hash] = objRefs
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// references to objects that are not in the store,
+	// corrupt objects are present and already reported
+	referenced := make(map[string]bool)
+	missing := make(map[string]bool)
+	for _, objRefs := range refs {
+		for _, ref := range objRefs {
+			referenced[ref.Hash] = true
+			if !present[ref.Hash] && !missing[ref.Hash] {
+				missing[ref.Hash] = true
+				problems = append(problems, FsckProblem{Kind: FsckMissing, Type: ref.Type, Hash: ref.Hash})
+			}
+		}
+	}
+
+	commits, blobs, err := reachabilityRoots()
+	if err != nil {
+		return nil, err
+	}
+	var roots []objectRef
+	for _, hash := range commits {
+		roots = append(roots, objectRef{hash, commitObject})
+	}
+	for _, hash := range blobs {
+		roots = append(roots, objectRef{hash, blobObject})
+	}
+
+	reachable := make(map[string]bool)
+	for len(roots) > 0 {
+		root := roots[len(roots)-1]
+		roots = roots[:len(roots)-1]
+		if reachable[root.Hash] {
+			continue
+		}
+		reachable[root.Hash] = true
+		if !present[root.Hash] && !missing[root.Hash] {
+			missing[root.Hash] = true
+			problems = append(problems, FsckProblem{Kind: FsckMissing, Type: root.Type, Hash: root.Hash})
+		}
+		roots = append(roots, refs[root.Hash]...)
+	}
+
+	// only report the tips of unreachable history, not everything below them
+	for hash, typ := range types {
+		if !reachable[hash] && !referenced[hash] {
+			problems = append(problems, FsckProblem{Kind: FsckDangling, Type: typ, Hash: hash})
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].Hash != problems[j].Hash {
+			return problems[i].Hash < problems[j].Hash
+		}
+		return problems[i].Kind < problems[j].Kind
+	})
+	return problems, nil
+}
+
+// validateCommit checks the syntax of a serialized commit
+// Returns the objects it references
+func validateCommit(data []byte) ([]objectRef, error) {
+	var refs []objectRef
+	hasTree, hasTimestamp := false, false
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break // rest is the message
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			if hasTree {
+				return nil, errors.New("multiple tree lines")
+			}
+			if !isValidHash(value) {
+				return nil, fmt.Errorf("invalid tree id '%s'", value)
+			}
+			hasTree = true
+			refs = append(refs, objectRef{value, treeObject})
+		case "parent":
+			if !isValidHash(value) {
+				return nil, fmt.Errorf("invalid parent id '%s'", value)
+			}
+			refs = append(refs, objectRef{value, commitObject})
+		case "timestamp":
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid timestamp '%s'", value)
+			}
+			hasTimestamp = true
+		case "author", "committer":
+			if seen[key] {
+				return nil, fmt.Errorf("multiple %s lines", key)
+			}
+			if _, err := parseSignature(value); err != nil {
+				return nil, err
+			}
+			seen[key] = true
+		default:
+			return nil, fmt.Errorf("unknown commit header '%s'", key)
+		}
+	}
+
+	if !hasTree {
+		return nil, errors.New("missing tree line")
+	}
+	if !hasTimestamp {
+		return nil, errors.New("missing timestamp line")
+	}
+	return refs, nil
+}
+
+// validateTree checks the syntax of a serialized tree
+// Returns the objects it references
+func validateTree(data []byte) ([]objectRef, error) {
+	entries, err := parseTreeEntries(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []objectRef
+	names := make(map[string]bool)
+	for _, e := range entries {
+		if e.Type != blobObject && e.Type != treeObject {
+			return nil, fmt.Errorf("invalid entry type '%s' for '%s'", e.Type, e.Name)
+		}
+		if !isValidMode(e.Type, e.Mode) {
+			return nil, fmt.Errorf("invalid mode %s for '%s'", formatMode(e.Mode), e.Name)
+		}
+		if e.Name == "" || e.Name == "." || e.Name == ".." || strings.Contains(e.Name, "/") {
+			return nil, fmt.Errorf("invalid entry name '%s'", e.Name)
+		}
+		if names[e.Name] {
+			return nil, fmt.Errorf("duplicate entry '%s'", e.Name)
+		}
+		if !isValidHash(e.Hash) {
+			return nil, fmt.Errorf("invalid id '%s' for '%s'", e.Hash, e.Name)
+		}
+		names[e.Name] = true
+		refs = append(refs, objectRef{e.Hash, e.Type})
+	}
+	return refs, nil
+}
+
+// validateChunkList checks the syntax of a chunked object
+// Returns the chunks it references
+func validateChunkList(data []byte) ([]objectRef, error) {
+	chunks, err := parseChunkList(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, errors.New("empty chunk list")
+	}
+
+	var refs []objectRef
+	for _, chunk := range chunks {
+		if !isValidHash(chunk.Hash) {
+			return nil, fmt.Errorf("invalid chunk id '%s'", chunk.Hash)
+		}
+		refs = append(refs, objectRef{chunk.Hash, blobObject})
+	}
+	return refs, nil
+}
+
+// unwrapReason returns the innermost error message of <err>
+func unwrapReason(err error) string {
+	for errors.Unwrap(err) != nil {
+		err = errors.Unwrap(err)
+	}
+	return err.Error()
+}
@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type GCOptions struct {
+	// only report what would be removed
+	DryRun bool
+	// unreachable objects younger than this are kept, they may
+	// belong to a commit that is being created right now
+	GracePeriod time.Duration
+}
+
+type GCResult struct {
+	// unreachable objects that were (or would be) removed
+	Pruned []string
+	// reachable objects moved into the new pack
+	Packed int
+}
+
+// CollectGarbage packs every object reachable from refs, HEAD and the index
+// and removes unreachable objects older than the grace period
+func CollectGarbage(opts GCOptions) (*GCResult, error) {
+	if err := checkDiskStore(); err != nil {
+		return nil, err
+	}
+	repoPath := "."
+	reachable, err := reachableObjects()
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk reachable objects: %w", err)
+	}
+	cutoff := time.Now().Add(-opts.GracePeriod)
+	store := NewLooseObjectStore(repoPath)
+
+	loose, err := looseObjectHashes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loose objects: %w", err)
+	}
+	isLoose := make(map[string]bool, len(loose))
+	pruned := make(map[string]bool)
+	for _, hash := range loose {
+		isLoose[hash] = true
+		if reachable[hash] {
+			continue
+		}
+		path, err := findObjectPath(repoPath, hash)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Before(cutoff) {
+			pruned[hash] = true
+		}
+	}
+
+	// packed objects have no age of their own, they are as old as their pack.
+	// recent unreachable ones are turned back into loose objects so they
+	// survive repacking and expire like any other loose object.
+	packs, err := loadPacks(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	type packedObject struct {
+		Pack    *packFile
+		ModTime time.Time
+	}
+	unreachablePacked := make(map[string]packedObject)
+	for _, p := range packs {
+		info, err := os.Stat(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range p.Entries {
+			if reachable[e.Hash] || isLoose[e.Hash] {
+				continue
+			}
+			// the youngest copy decides
+			if prev, ok := unreachablePacked[e.Hash]; !ok || info.ModTime().After(prev.ModTime) {
+				unreachablePacked[e.Hash] = packedObject{p, info.ModTime()}
+			}
+		}
+	}
+	rescued := make(map[string]packedObject)
+	for hash, obj := range unreachablePacked {
+		if obj.ModTime.Before(cutoff) {
+			pruned[hash] = true
+		} else {
+			rescued[hash] = obj
+		}
+	}
+
+	result := &GCResult{}
+	for hash := range pruned {
+		result.Pruned = append(result.Pruned, hash)
+	}
+	sort.Strings(result.Pruned)
+	if opts.DryRun {
+		return result, nil
+	}
+
+	for hash, obj := range rescued {
+		typ, data, err := obj.Pack.read(hash)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.writeLoose(hash, encodeObject(typ, data)); err != nil {
+			return nil, err
+		}
+		os.Chtimes(objectPath(repoPath, hash), obj.ModTime, obj.ModTime)
+	}
+
+	result.Packed, err = repack(repoPath, func(hash string) bool {
+		return reachable[hash]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range result.Pruned {
+		if err := removeLooseObject(repoPath, hash); err != nil {
+			return nil, fmt.Errorf("failed to remove object %s: %w", hash, err)
+		}
+	}
+
+	return result, nil
+}
+
+// reachabilityRoots returns the commits pointed to by refs and a
+// detached HEAD, and the blobs staged in the index
+func reachabilityRoots() ([]string, []string, error) {
+	var commits, blobs []string
+
+	refsDir := filepath.Join(config.REPO_DIR, config.REFS_DIR)
+	err := filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isRefName(d.Name()) {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if hash := strings.TrimSpace(string(data)); hash != "" {
+			commits = append(commits, hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read refs: %w", err)
+	}
+
+	head, err := os.ReadFile(filepath.Join(config.REPO_DIR, config.HEAD_PATH))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if ref := strings.TrimSpace(string(head)); !strings.HasPrefix(ref, "ref:") {
+		commits = append(commits, ref)
+	}
+
+	index, err := loadIndex()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	if index != nil {
+		for _, entry := range *index {
+			blobs = append(blobs, entry.Hash)
+		}
+	}
+
+	return commits, blobs, nil
+}
+
+// reachableObjects returns the set of objects reachable from
+// the roots through commit parents and tree entries
+func reachableObjects() (map[string]bool, error) {
+	commits, blobs, err := reachabilityRoots()
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := make(map[string]bool)
+	for _, hash := range blobs {
+		if err := markBlobReachable(hash, reachable); err != nil {
+			return nil, err
+		}
+	}
+
+	for len(commits) > 0 {
+		hash := commits[len(commits)-1]
+		commits = commits[:len(commits)-1]
+		if reachable[hash] {
+			continue
+		}
+		reachable[hash] = true
+
+		commit, err := LoadCommit(".", hash)
+		if err != nil {
+			return nil, err
+		}
+		if err := markTreeReachable(commit.TreeID, reachable); err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit.ParentIDs...)
+	}
+
+	return reachable, nil
+}
+
+// markTreeReachable adds the tree with <treeHash> and everything
+// below it to <reachable>
+func markTreeReachable(treeHash string, reachable map[string]bool) error {
+	if reachable[treeHash] {
+		return nil
+	}
+	reachable[treeHash] = true
+
+	tree, err := loadTree(treeHash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries {
+		if entry.Type == treeObject {
+			if err := markTreeReachable(entry.Hash, reachable); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := markBlobReachable(entry.Hash, reachable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markBlobReachable adds the blob with <blobHash> to <reachable>,
+// along with its chunks when it is stored chunked
+func markBlobReachable(blobHash string, reachable map[string]bool) error {
+	if reachable[blobHash] {
+		return nil
+	}
+	reachable[blobHash] = true
+
+	// only the header is read, most blobs are not chunked
+	typ, err := NewLooseObjectStore(".").Type(blobHash)
+	if err != nil {
+		return err
+	}
+	if typ != chunkedObject {
+		return nil
+	}
+	chunks, err := blobChunks(".", blobHash)
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", blobHash, err)
+	}
+	for _, chunk := range chunks {
+		reachable[chunk.Hash] = true
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"fmt"
+	"jit/config"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature tells who authored or committed a commit and when
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// roles a Signature is made for, they prefix the environment
+// variables that override the identity, e.g. JIT_AUTHOR_NAME
+const (
+	roleAuthor    = "AUTHOR"
+	roleCommitter = "COMMITTER"
+)
+
+// String formats the signature as commits record it
+// <name> <<email>> <unix time> <+hhmm>
+func (s Signature) String() string {
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.When.Unix(), s.When.Format("-0700"))
+}
+
+// IsZero reports whether the signature is missing, commits made
+// before signatures were recorded have none
+func (s Signature) IsZero() bool {
+	return s.Name == "" && s.Email == ""
+}
+
+// parseSignature parses a signature formatted by Signature.String
+func parseSignature(value string) (Signature, error) {
+	open := strings.LastIndex(value, "<")
+	end := strings.LastIndex(value, ">")
+	if open < 0 || end < open {
+		return Signature{}, fmt.Errorf("malformed signature '%s'", value)
+	}
+	when, err := parseSignatureTime(strings.TrimSpace(value[end+1:]))
+	if err != nil {
+		return Signature{}, fmt.Errorf("malformed signature '%s': %w", value, err)
+	}
+	return Signature{
+		Name:  strings.TrimSpace(value[:open]),
+		Email: value[open+1 : end],
+		When:  when,
+	}, nil
+}
+
+// parseSignatureTime parses "<unix time> <+hhmm>"
+func parseSignatureTime(value string) (time.Time, error) {
+	unix, zone, ok := strings.Cut(value, " ")
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid time '%s'", value)
+	}
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s'", value)
+	}
+	offset, err := time.Parse("-0700", zone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time zone '%s'", zone)
+	}
+	return time.Unix(seconds, 0).In(offset.Location()), nil
+}
+
+// newSignature returns the identity of the <role> of a commit made at
+// <when>. The name and email come from JIT_<role>_NAME and
+// JIT_<role>_EMAIL, then from the repository and the user config, then
+// from the system account. JIT_<role>_DATE, "<unix time> <+hhmm>" or
+// RFC 3339, overrides <when>
+func newSignature(role string, when time.Time) (Signature, error) {
+	sig := Signature{
+		Name:  os.Getenv("JIT_" + role + "_NAME"),
+		Email: os.Getenv("JIT_" + role + "_EMAIL"),
+		When:  when,
+	}
+	if sig.Name == "" {
+		sig.Name = setting(".", config.USER_NAME_KEY)
+	}
+	if sig.Email == "" {
+		sig.Email = setting(".", config.USER_EMAIL_KEY)
+	}
+	if sig.Name == "" || sig.Email == "" {
+		account, err := user.Current()
+		if err != nil {
+			return Signature{}, fmt.Errorf("unknown identity, set %s and %s: %w",
+				config.USER_NAME_KEY, config.USER_EMAIL_KEY, err)
+		}
+		if sig.Name == "" {
+			sig.Name = account.Username
+		}
+		if sig.Email == "" {
+			host, _ := os.Hostname()
+			sig.Email = account.Username + "@" + host
+		}
+	}
+	if strings.ContainsAny(sig.Name+sig.Email, "<>\n") {
+		return Signature{}, fmt.Errorf("name and email can't contain '<', '>' or newlines")
+	}
+
+	if date := os.Getenv("JIT_" + role + "_DATE"); date != "" {
+		parsed, err := parseSignatureTime(date)
+		if err != nil {
+			if parsed, err = time.Parse(time.RFC3339, date); err != nil {
+				return Signature{}, fmt.Errorf("invalid JIT_%s_DATE '%s'", role, date)
+			}
+		}
+		sig.When = parsed
+	}
+	return sig, nil
+}
@@ -1,124 +1,293 @@
 package internal
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"jit/config"
+	"jit/fsutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
+// The index is a binary file, entries are sorted by path.
+// format
+// "JNDX" <version uint32> <hash size uint32> <entry count uint32>
+// <entry>...
+// <hash of everything above, raw bytes>
+//
+// entry
+// <ctime int64> <mtime int64> <size int64> <inode uint64> <stat mode uint32>
+// <file mode uint32> <raw hash> <path length uint16> <path>
+//
+// Version 2 entries have no file mode. Version 1 was a text file
+// of "<hash> <path>" lines. Both are still read.
+const (
+	indexMagic   = "JNDX"
+	indexVersion = 3
+	indexName    = "index"
+)
+
 type IndexEntry struct {
 	Hash     string
 	Filepath string
+	// file mode recorded in trees, see mode.go
+	Mode uint32
+	// stat data of the file when it was staged
+	Stat FileStat
 }
 
 type Index []IndexEntry
 
-// AddToIndex adds a file with <path> to the staging area
+// AddToIndex adds a file, or every file below a directory, with <path>
+// to the staging area
 func AddToIndex(path string) error {
-
-	absPath, err := filepath.Abs(path)
-	if err != nil {
+	if _, err := os.Lstat(path); err != nil {
 		return err
 	}
+	_, err := StageFiles([]string{path}, false)
+	return err
+}
 
-	info, err := os.Stat(absPath)
-	if err != nil {
-		return err
+// StageFiles updates the index to match the working directory below
+// <paths>, the whole working directory when no paths are given: new and
+// modified files are staged and deleted files are unstaged. Ignored files
+// are skipped. With <trackedOnly> files that are not staged yet are left alone.
+// Returns the changes made to the index
+func StageFiles(paths []string, trackedOnly bool) ([]FileChange, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
 	}
-
-	if info.IsDir() {
-		entries, err := os.ReadDir(absPath)
-		if err != nil {
-			return err
-		}
-		hasFiles := false
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				hasFiles = true
-				break
+	paths = append([]string{}, paths...)
+	for i, path := range paths {
+		if filepath.IsAbs(path) {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			if path, err = filepath.Rel(cwd, path); err != nil {
+				return nil, err
 			}
 		}
-
-		if !hasFiles {
-			return nil
+		paths[i] = indexPathOf(path)
+		if paths[i] == ".." || strings.HasPrefix(paths[i], "../") {
+			return nil, fmt.Errorf("'%s' is outside the repository", path)
 		}
-		// walk
-		return filepath.Walk(
-			path,
-			func(filePath string, fileInfo fs.FileInfo, walkErr error) error {
-				if walkErr != nil {
-					return walkErr
-				}
-				// skip dirs, process only their files
-				if fileInfo.IsDir() {
-					return nil
-				}
-
-				return AddToIndex(filePath)
-			})
 	}
 
-	// handle files
-	content, err := os.ReadFile(absPath)
+	// hold the lock from reading the index to writing it back
+	// so entries added by other processes are not lost
+	indexPath := filepath.Join(config.REPO_DIR, indexName)
+	lock, err := acquireLock(indexPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer lock.release()
 
-	hash := ComputeHash(content)
-
-	// write obj to if does not exist
-	objectPath := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, hash)
-	if _, err := os.Stat(objectPath); err != nil {
-		if err := os.WriteFile(objectPath, content, 0644); err != nil {
-			return err
-		}
+	index, _, err := readIndexFile(".")
+	if errors.Is(err, fs.ErrNotExist) {
+		index = &Index{}
+	} else if err != nil {
+		return nil, err
+	}
+	working, err := scanWorkingTree(".", paths)
+	if err != nil {
+		return nil, err
 	}
 
-	// write to index
+	staged := make(map[string]int)
+	for i, entry := range *index {
+		staged[indexPathOf(entry.Filepath)] = i
+	}
+	matched := make(map[string]bool)
+	var changes []FileChange
 
-	indexPath := filepath.Join(config.REPO_DIR, "index")
-	indexEntries := map[string]string{}
-	if _, err := os.Stat(indexPath); err == nil {
-		content, err := os.ReadFile(indexPath)
-		if err != nil {
-			return err
+	for _, entry := range *working {
+		for _, path := range paths {
+			if isUnder(entry.Filepath, path) {
+				matched[path] = true
+			}
 		}
 
-		lines := strings.Split(string(content), "\n")
-		for _, line := range lines {
-			if line == "" {
+		i, tracked := staged[entry.Filepath]
+		if !tracked {
+			if trackedOnly {
 				continue
 			}
-			parts := strings.Fields(line)
-			if len(parts) == 2 {
-				indexEntries[parts[1]] = line
+			hash, err := storeWorkingFile(entry)
+			if err != nil {
+				return nil, err
+			}
+			entry.Hash = hash
+			*index = append(*index, entry)
+			changes = append(changes, FileChange{entry.Filepath, StatusNew})
+			continue
+		}
+
+		if old := (*index)[i]; old.Hash != entry.Hash || old.Mode != entry.Mode {
+			hash, err := storeWorkingFile(entry)
+			if err != nil {
+				return nil, err
 			}
+			entry.Hash = hash
+			changes = append(changes, FileChange{entry.Filepath, StatusModified})
 		}
+		// refreshed stat data spares rehashing the file next time
+		(*index)[i] = entry
 	}
 
-	indexEntries[path] = fmt.Sprintf("%s %s", hash, path)
+	// staged files that are gone from the working directory,
+	// ignored files are not scanned but may still exist
+	var kept Index
+	for _, entry := range *index {
+		path := indexPathOf(entry.Filepath)
+		under := false
+		for _, p := range paths {
+			if isUnder(path, p) {
+				matched[p] = true
+				under = true
+			}
+		}
+		if under {
+			if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+				changes = append(changes, FileChange{path, StatusDeleted})
+				continue
+			}
+		}
+		kept = append(kept, entry)
+	}
 
-	var updatedIndexContent strings.Builder
-	for _, entry := range indexEntries {
-		updatedIndexContent.WriteString(entry + "\n")
+	for _, path := range paths {
+		// an empty working directory is nothing to complain about
+		if !matched[path] && path != "." {
+			return nil, fmt.Errorf("pathspec '%s' did not match any files", path)
+		}
 	}
 
-	return os.WriteFile(indexPath, []byte(updatedIndexContent.String()), 0644)
+	if err := writeIndexFile(".", &kept); err != nil {
+		return nil, fmt.Errorf("failed to save index: %w", err)
+	}
+	sortChanges(changes)
+	return changes, nil
 }
 
-var index Index = nil
+// storeWorkingFile writes the blob of the scanned working file <entry>
+// unless it is stored already
+// Returns the blob id, which differs from entry.Hash if the file changed
+// since it was scanned
+func storeWorkingFile(entry IndexEntry) (string, error) {
+	if hasObject(".", entry.Hash) {
+		return entry.Hash, nil
+	}
+	hash, err := writeBlobFile(entry.Filepath)
+	if err != nil {
+		return "", fmt.Errorf("failed to add '%s': %w", entry.Filepath, err)
+	}
+	return hash, nil
+}
 
 // loadIndex reads the index file and returns an Index
 func loadIndex() (*Index, error) {
+	return ReadIndex(".")
+}
+
+// ReadIndex returns the staged files of the repository at <repoPath>
+func ReadIndex(repoPath string) (*Index, error) {
+	index, _, err := readIndexFile(repoPath)
+	return index, err
+}
 
-	data, err := os.ReadFile(filepath.Join(config.REPO_DIR, "index"))
+// readIndexFile reads the index of the repository at <repoPath>
+// Returns the index and the modification time of the index file in
+// nanoseconds, entries modified at or after it may be racily clean
+func readIndexFile(repoPath string) (*Index, int64, error) {
+	indexPath := filepath.Join(repoPath, config.REPO_DIR, indexName)
+	data, err := os.ReadFile(indexPath)
 	if err != nil {
+		return nil, 0, err
+	}
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var index *Index
+	if bytes.HasPrefix(data, []byte(indexMagic)) {
+		index, err = parseIndex(repoPath, data)
+	} else {
+		index = parseTextIndex(data)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read index: %w", err)
+	}
+	return index, info.ModTime().UnixNano(), nil
+}
+
+// parseIndex parses a binary index
+func parseIndex(repoPath string, data []byte) (*Index, error) {
+	h := newHasher(repoPath)
+	if len(data) < h.Size() {
+		return nil, errors.New("index file is truncated")
+	}
+	content, checksum := data[:len(data)-h.Size()], data[len(data)-h.Size():]
+	h.Write(content)
+	if !bytes.Equal(h.Sum(nil), checksum) {
+		return nil, errors.New("index file is corrupt, checksum mismatch")
+	}
+
+	r := bytes.NewReader(content[len(indexMagic):])
+	var header struct {
+		Version  uint32
+		HashSize uint32
+		Count    uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
 		return nil, err
 	}
+	if header.Version != indexVersion && header.Version != 2 {
+		return nil, fmt.Errorf("unsupported index version %d", header.Version)
+	}
+
+	index := make(Index, 0, header.Count)
+	rawHash := make([]byte, header.HashSize)
+	for i := uint32(0); i < header.Count; i++ {
+		var entry IndexEntry
+		var pathLen uint16
+		if err := binary.Read(r, binary.BigEndian, &entry.Stat); err != nil {
+			return nil, fmt.Errorf("malformed index entry: %w", err)
+		}
+		if header.Version == 2 {
+			entry.Mode = modeFromStat(entry.Stat)
+		} else if err := binary.Read(r, binary.BigEndian, &entry.Mode); err != nil {
+			return nil, fmt.Errorf("malformed index entry: %w", err)
+		}
+		if _, err := io.ReadFull(r, rawHash); err != nil {
+			return nil, fmt.Errorf("malformed index entry: %w", err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &pathLen); err != nil {
+			return nil, fmt.Errorf("malformed index entry: %w", err)
+		}
+		path := make([]byte, pathLen)
+		if _, err := io.ReadFull(r, path); err != nil {
+			return nil, fmt.Errorf("malformed index entry: %w", err)
+		}
+		entry.Hash = hex.EncodeToString(rawHash)
+		entry.Filepath = string(path)
+		index = append(index, entry)
+	}
+	return &index, nil
+}
 
+// parseTextIndex parses a version 1 index, it has no stat data
+func parseTextIndex(data []byte) *Index {
+	var index Index
 	lines := strings.Split(string(data), "\n")
 	for _, l := range lines {
 		l = strings.TrimSpace(l)
@@ -136,102 +305,216 @@ func loadIndex() (*Index, error) {
 		}
 
 	}
-
-	return &index, nil
+	return &index
 }
 
 // saveIndex saves the Index to file
 func saveIndex(index *Index) error {
-	indexPath := filepath.Join(config.REPO_DIR, "index")
+	indexPath := filepath.Join(config.REPO_DIR, indexName)
+	lock, err := acquireLock(indexPath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
 
-	var sb strings.Builder
-	for _, entry := range *index {
-		indexEntry := fmt.Sprintf("%s %s\n", entry.Hash, entry.Filepath)
-		sb.WriteString(indexEntry)
+	return writeIndexFile(".", index)
+}
+
+// writeIndexFile writes <index> to the index of the repository at
+// <repoPath>, the caller holds the index lock
+func writeIndexFile(repoPath string, index *Index) error {
+	entries := append(Index{}, *index...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Filepath < entries[j].Filepath
+	})
+
+	h := newHasher(repoPath)
+	var buf bytes.Buffer
+	w := bufio.NewWriter(io.MultiWriter(&buf, h))
+	w.WriteString(indexMagic)
+	binary.Write(w, binary.BigEndian, uint32(indexVersion))
+	binary.Write(w, binary.BigEndian, uint32(h.Size()))
+	binary.Write(w, binary.BigEndian, uint32(len(entries)))
+	for _, entry := range entries {
+		rawHash, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(rawHash) != h.Size() {
+			return fmt.Errorf("invalid object id '%s' for '%s'", entry.Hash, entry.Filepath)
+		}
+		if len(entry.Filepath) > 0xffff {
+			return fmt.Errorf("path too long: '%s'", entry.Filepath)
+		}
+		binary.Write(w, binary.BigEndian, entry.Stat)
+		binary.Write(w, binary.BigEndian, modeOrDefault(entry.Mode))
+		w.Write(rawHash)
+		binary.Write(w, binary.BigEndian, uint16(len(entry.Filepath)))
+		w.WriteString(entry.Filepath)
+	}
+	if err := w.Flush(); err != nil {
+		return err
 	}
+	buf.Write(h.Sum(nil))
+
+	indexPath := filepath.Join(repoPath, config.REPO_DIR, indexName)
+	return fsutil.WriteFileAtomic(indexPath, buf.Bytes(), 0644)
+}
+
+// indexPathOf returns <path> as the index stores it,
+// relative with forward slashes
+func indexPathOf(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
 
-	return os.WriteFile(indexPath, []byte(sb.String()), 0644)
+// isUnder reports whether the index path <entryPath> is <path>
+// itself or a file below the directory <path>
+func isUnder(entryPath, path string) bool {
+	entryPath, path = indexPathOf(entryPath), indexPathOf(path)
+	return path == "." || entryPath == path || strings.HasPrefix(entryPath, path+"/")
+}
+
+// isUnchanged reports whether the file described by <info> still has
+// the content <entry> was staged with, judging by its stat data alone.
+// Files modified at or after <indexTime>, when the index was written, may
+// have changed again within the same timestamp tick and are never trusted
+func (entry IndexEntry) isUnchanged(info fs.FileInfo, indexTime int64) bool {
+	if entry.Stat.MTime == 0 || entry.Stat.MTime >= indexTime {
+		return false
+	}
+	return statFromInfo(info) == entry.Stat
 }
 
 // CreateFakeIndex generates a fake index from the current working directory
-// Used for building working directory tree for change detection
+// Used for building working directory tree for change detection.
+// Files whose stat data matches their index entry are not rehashed
 func CreateFakeIndex(basePath string) (*Index, error) {
+	return scanWorkingTree(basePath, []string{"."})
+}
+
+// scanWorkingTree returns index entries for the files below <roots>, paths
+// relative to <basePath>. Ignored files and the repository are skipped,
+// roots that don't exist are skipped too.
+func scanWorkingTree(basePath string, roots []string) (*Index, error) {
 	var fakeIndex Index
 	patterns, err := LoadIgnorePatterns()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load .jitignore: %w", err)
 	}
 
-	// Walk the directory structure starting from basePath
-	err = filepath.Walk(basePath, func(path string, info fs.FileInfo, err error) error {
+	staged := make(map[string]IndexEntry)
+	index, indexTime, err := readIndexFile(basePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if index != nil {
+		for _, entry := range *index {
+			staged[entry.Filepath] = entry
+		}
+	}
+
+	seen := make(map[string]bool)
+	walk := func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		// Convert the file path to a relative path
+		relPath, err := filepath.Rel(basePath, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path for '%s': %w", path, err)
+		}
+		relPath = filepath.ToSlash(relPath) // Normalize for consistency
+
 		// Skip directories and the .jit repository
 		if info.IsDir() {
-			if strings.HasPrefix(path, config.REPO_DIR) {
+			if relPath == config.REPO_DIR || strings.HasPrefix(relPath, config.REPO_DIR+"/") {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		if IsIgnonored(path, patterns) {
-			fmt.Printf("skipping ingored file: %s\n", path)
+		if IsIgnonored(path, patterns) || seen[relPath] {
 			return nil
 		}
+		seen[relPath] = true
 
-		// Compute the hash of the file content
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read file '%s': %w", path, err)
+		// Compute the hash of the file content unless it is known
+		entry, ok := staged[relPath]
+		if ok && entry.isUnchanged(info, indexTime) {
+			fakeIndex = append(fakeIndex, entry)
+			return nil
 		}
-		hash := ComputeHash(content)
-
-		// Convert the file path to a relative path
-		relPath, err := filepath.Rel(basePath, path)
+		hash, err := hashBlobFile(path)
 		if err != nil {
-			return fmt.Errorf("failed to get relative path for '%s': %w", path, err)
+			return fmt.Errorf("failed to read file '%s': %w", path, err)
 		}
-		relPath = filepath.ToSlash(relPath) // Normalize for consistency
 
 		// Add to fake index
 		fakeIndex = append(fakeIndex, IndexEntry{
 			Filepath: relPath,
 			Hash:     hash,
+			Mode:     modeFromInfo(info, entry.Mode),
+			Stat:     statFromInfo(info),
 		})
 
 		return nil
-	})
+	}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create fake index: %w", err)
+	for _, root := range roots {
+		rootPath := filepath.Join(basePath, root)
+		if _, err := os.Lstat(rootPath); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := filepath.Walk(rootPath, walk); err != nil {
+			return nil, fmt.Errorf("failed to create fake index: %w", err)
+		}
 	}
 
 	return &fakeIndex, nil
 }
 
-// updateIndexFromTree updates the index given a tree hash
-func updateIndexFromTree(treeHash string) error {
-	tree, err := loadTree(treeHash)
+// updateIndexFromTree replaces the index with the files of the tree with
+// <treeHash>. Entries keep their stat data while their content is unchanged,
+// with <fromWorking> the stat data is taken from the working directory,
+// which must hold the files of the tree
+func updateIndexFromTree(treeHash string, fromWorking bool) error {
+	files := make(map[string]TreeEntry)
+	if err := walkTree("", treeHash, files); err != nil {
+		return err
+	}
+
+	indexPath := filepath.Join(config.REPO_DIR, indexName)
+	lock, err := acquireLock(indexPath)
 	if err != nil {
-		return fmt.Errorf("failed to load tree: %w", err)
+		return err
+	}
+	defer lock.release()
+
+	current, _, err := readIndexFile(".")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	stats := make(map[string]IndexEntry)
+	if current != nil {
+		for _, entry := range *current {
+			stats[indexPathOf(entry.Filepath)] = entry
+		}
 	}
 
 	var index Index
-	for _, entry := range tree.Entries {
-		fmt.Println(entry)
-		index = append(index, IndexEntry{
-			Filepath: entry.Name,
-			Hash:     entry.Hash,
-		})
+	for path, file := range files {
+		entry := IndexEntry{Hash: file.Hash, Filepath: filepath.ToSlash(path), Mode: file.Mode}
+		if fromWorking {
+			if info, err := os.Lstat(path); err == nil {
+				entry.Stat = statFromInfo(info)
+			}
+		} else if old, ok := stats[entry.Filepath]; ok && old.Hash == file.Hash && old.Mode == file.Mode {
+			entry.Stat = old.Stat
+		}
+		index = append(index, entry)
 	}
 
-	err = saveIndex(&index)
-	if err != nil {
+	if err := writeIndexFile(".", &index); err != nil {
 		return fmt.Errorf("failed to save index: %w", err)
 	}
-
 	return nil
 }
 
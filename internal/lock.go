@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/fsutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files that are read, modified and written back (the index, refs, HEAD)
+// are guarded by a lock file <path>.lock, created with O_EXCL so only one
+// process holds it at a time. The lock file holds the pid of its owner,
+// which lets a lock left behind by a crashed process be detected.
+// A stale lock is only removed while holding <path>.lock.lock.
+const (
+	lockSuffix = ".lock"
+	// how long to wait for another process to release a lock
+	lockTimeout = time.Second
+	// locks without a readable pid older than this are left over by a crash
+	staleLockAge = 10 * time.Minute
+)
+
+// ErrLocked is returned when another jit process holds a lock
+var ErrLocked = errors.New("another jit process is running")
+
+type lockFile struct {
+	path     string
+	lockPath string
+}
+
+var (
+	heldLocksMu sync.Mutex
+	heldLocks   = map[*lockFile]bool{}
+)
+
+// acquireLock takes the lock on the file at <path>, waiting up to
+// lockTimeout for another process to release it
+func acquireLock(path string) (*lockFile, error) {
+	lockPath := path + lockSuffix
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("failed to lock '%s': %w", path, err)
+			}
+
+			l := &lockFile{path: path, lockPath: lockPath}
+			heldLocksMu.Lock()
+			heldLocks[l] = true
+			heldLocksMu.Unlock()
+			return l, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to lock '%s': %w", path, err)
+		}
+
+		if isStaleLock(lockPath) {
+			broken, err := breakStaleLock(lockPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to lock '%s': %w", path, err)
+			}
+			if broken {
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf(
+				"%w: '%s' exists, remove it if no other jit process is running",
+				ErrLocked, lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// breakStaleLock removes the lock file at <lockPath> if it is still stale
+// once its own lock is held. Without that, a process that found the lock
+// stale could remove the lock another process took after removing the
+// stale one, and both would go ahead.
+// <broken> is false when another process is breaking the lock
+func breakStaleLock(lockPath string) (broken bool, err error) {
+	breakerPath := lockPath + lockSuffix
+	f, err := os.OpenFile(breakerPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		// a crash while breaking the lock leaves it for the user to remove
+		if info, err := os.Stat(breakerPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			return false, fmt.Errorf(
+				"%w: '%s' exists, remove it if no other jit process is running",
+				ErrLocked, breakerPath)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	l := &lockFile{path: lockPath, lockPath: breakerPath}
+	heldLocksMu.Lock()
+	heldLocks[l] = true
+	heldLocksMu.Unlock()
+	defer l.release()
+	if err != nil {
+		return false, err
+	}
+
+	if isStaleLock(lockPath) {
+		if err := os.Remove(lockPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// isStaleLock reports whether the lock file at <lockPath> was left
+// behind by a process that no longer runs
+func isStaleLock(lockPath string) bool {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		// released in the meantime, just retry
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err == nil && pid > 0 {
+		return !processAlive(pid)
+	}
+
+	// the owner may still be writing its pid
+	info, err := os.Stat(lockPath)
+	return err == nil && time.Since(info.ModTime()) > staleLockAge
+}
+
+// release removes the lock, it is safe to call more than once
+func (l *lockFile) release() {
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+	if heldLocks[l] {
+		delete(heldLocks, l)
+		os.Remove(l.lockPath)
+	}
+}
+
+// ReleaseLocks removes every lock held by this process,
+// for use when the process is interrupted
+func ReleaseLocks() {
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+	for l := range heldLocks {
+		os.Remove(l.lockPath)
+		delete(heldLocks, l)
+	}
+}
+
+// isRefName reports whether <name> is a ref rather than
+// a lock or temporary file next to one
+func isRefName(name string) bool {
+	return !strings.HasPrefix(name, ".") && !strings.HasSuffix(name, lockSuffix)
+}
+
+// readRef returns the hash stored in the ref file at <refPath>,
+// "" when the ref does not exist
+func readRef(refPath string) (string, error) {
+	data, err := os.ReadFile(refPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeRef points the ref file at <refPath> to <hash>
+func writeRef(refPath, hash string) error {
+	lock, err := acquireLock(refPath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	return fsutil.WriteFileAtomic(refPath, []byte(hash+"\n"), 0644)
+}
+
+// updateRef points the ref file at <refPath> to <newHash> only if it still
+// points to <oldHash> ("" for a ref that does not exist yet), so updates
+// made by another process in the meantime are not lost
+func updateRef(refPath, oldHash, newHash string) error {
+	lock, err := acquireLock(refPath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	current, err := readRef(refPath)
+	if err != nil {
+		return err
+	}
+	if current != oldHash {
+		return fmt.Errorf(
+			"'%s' was updated by another process: expected '%s', found '%s'",
+			refPath, oldHash, current)
+	}
+	return fsutil.WriteFileAtomic(refPath, []byte(newHash+"\n"), 0644)
+}
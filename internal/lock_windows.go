@@ -0,0 +1,16 @@
+//go:build windows
+
+package internal
+
+import "syscall"
+
+// processAlive reports whether a process with <pid> is running
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// processes of other users can't be opened but are running
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	syscall.CloseHandle(h)
+	return true
+}
@@ -0,0 +1,310 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"jit/config"
+	"jit/fsutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LooseObjectStore is the on-disk object database of a repository.
+// New objects are written as loose zlib-compressed files under
+// .jit/objects/ab/cdef..., `jit repack` moves them into pack files.
+type LooseObjectStore struct {
+	RepoPath string
+}
+
+func NewLooseObjectStore(repoPath string) *LooseObjectStore {
+	return &LooseObjectStore{RepoPath: repoPath}
+}
+
+// Get reads the object with <hash>
+// loose objects take precedence over packed ones
+func (s *LooseObjectStore) Get(hash string) (string, []byte, error) {
+	path, err := findObjectPath(s.RepoPath, hash)
+	if err != nil {
+		typ, data, packErr := readPackedObject(s.RepoPath, hash)
+		if packErr == nil {
+			return typ, data, nil
+		}
+		if errors.Is(packErr, os.ErrNotExist) {
+			packErr = ErrObjectNotFound
+		}
+		return "", nil, fmt.Errorf("failed to read object %s: %w", hash, packErr)
+	}
+	compressed, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+
+	typ, data, err := inflateObject(compressed)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+
+	return typ, data, nil
+}
+
+// Open streams the object with <hash>, deltified packed objects are
+// resolved in memory
+func (s *LooseObjectStore) Open(hash string) (string, int64, io.ReadCloser, error) {
+	path, err := findObjectPath(s.RepoPath, hash)
+	if err != nil {
+		typ, size, r, packErr := openPackedObject(s.RepoPath, hash)
+		if packErr == nil {
+			return typ, size, r, nil
+		}
+		if errors.Is(packErr, os.ErrNotExist) {
+			packErr = ErrObjectNotFound
+		}
+		return "", 0, nil, fmt.Errorf("failed to read object %s: %w", hash, packErr)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		f.Close()
+		return "", 0, nil, fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	br := bufio.NewReader(zr)
+	header, err := br.ReadString(0)
+	if err != nil {
+		zr.Close()
+		f.Close()
+		return "", 0, nil, fmt.Errorf("failed to read object %s: missing object header", hash)
+	}
+	typ, size, err := parseObjectHeader(strings.TrimSuffix(header, "\x00"))
+	if err != nil {
+		zr.Close()
+		f.Close()
+		return "", 0, nil, fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+
+	return typ, size, &sizedReader{r: br, remaining: size, closers: []io.Closer{zr, f}}, nil
+}
+
+// Type returns the type of the object with <hash>, only its header is read
+func (s *LooseObjectStore) Type(hash string) (string, error) {
+	path, err := findObjectPath(s.RepoPath, hash)
+	if err != nil {
+		typ, packErr := packedObjectType(s.RepoPath, hash)
+		if packErr == nil {
+			return typ, nil
+		}
+		if errors.Is(packErr, os.ErrNotExist) {
+			packErr = ErrObjectNotFound
+		}
+		return "", fmt.Errorf("failed to read object %s: %w", hash, packErr)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	defer zr.Close()
+	// a small buffer so that no more than the header is inflated
+	header, err := bufio.NewReaderSize(zr, 64).ReadString(0)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object %s: missing object header", hash)
+	}
+	typ, _, err := parseObjectHeader(strings.TrimSuffix(header, "\x00"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	return typ, nil
+}
+
+// PutStream writes <size> bytes from <r> to a loose object,
+// compressing and hashing on the fly
+func (s *LooseObjectStore) PutStream(typ string, size int64, r io.Reader) (string, error) {
+	objectsDir := filepath.Join(s.RepoPath, config.REPO_DIR, config.OBJECTS_DIR)
+	tmp, err := os.CreateTemp(objectsDir, "tmp-obj-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create object: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := newHasher(s.RepoPath)
+	zw := zlib.NewWriter(tmp)
+	w := io.MultiWriter(zw, hasher)
+
+	fmt.Fprintf(w, "%s %d\x00", typ, size)
+	n, err := io.Copy(w, io.LimitReader(r, size+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to write object: %w", err)
+	}
+	if n != size {
+		return "", fmt.Errorf("failed to write object: expected %d bytes, got %d", size, n)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress object: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write object: %w", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if s.Has(hash) {
+		return hash, nil
+	}
+
+	path := objectPath(s.RepoPath, hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	if err := fsutil.SyncDir(filepath.Dir(path)); err != nil {
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	return hash, nil
+}
+
+// Put writes a loose object unless the object is already stored
+func (s *LooseObjectStore) Put(typ string, data []byte) (string, error) {
+	raw := encodeObject(typ, data)
+	hash := computeRepoHash(s.RepoPath, raw)
+
+	if s.Has(hash) {
+		// objects are immutable, nothing to do
+		return hash, nil
+	}
+
+	return hash, s.writeLoose(hash, raw)
+}
+
+// writeLoose writes the encoded object <raw> to its loose object file
+func (s *LooseObjectStore) writeLoose(hash string, raw []byte) error {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return fmt.Errorf("failed to compress object %s: %w", hash, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress object %s: %w", hash, err)
+	}
+
+	path := objectPath(s.RepoPath, hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	// a reader must never see a truncated object
+	if err := fsutil.WriteFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+
+	return nil
+}
+
+// Has reports whether the object with <hash> is stored loose or packed
+func (s *LooseObjectStore) Has(hash string) bool {
+	if _, err := findObjectPath(s.RepoPath, hash); err == nil {
+		return true
+	}
+	return hasPackedObject(s.RepoPath, hash)
+}
+
+// Iterate calls <fn> for every loose object, then for every packed object
+// that is not also stored loose
+func (s *LooseObjectStore) Iterate(fn func(hash string) error) error {
+	loose, err := looseObjectHashes(s.RepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list loose objects: %w", err)
+	}
+	seen := make(map[string]bool, len(loose))
+	for _, hash := range loose {
+		seen[hash] = true
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+
+	packs, err := loadPacks(s.RepoPath)
+	if err != nil {
+		return err
+	}
+	for _, p := range packs {
+		for _, e := range p.Entries {
+			if seen[e.Hash] {
+				continue
+			}
+			seen[e.Hash] = true
+			if err := fn(e.Hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeLooseObject deletes the loose copy of <hash> and its
+// fan-out directory once it is empty
+func removeLooseObject(repoPath, hash string) error {
+	path, err := findObjectPath(repoPath, hash)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != filepath.Join(
+		repoPath, config.REPO_DIR, config.OBJECTS_DIR) {
+		// fails while other objects share the directory
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// looseObjectHashes returns the hashes of all loose objects
+// in the repository at <repoPath>, including flat ones
+func looseObjectHashes(repoPath string) ([]string, error) {
+	objectsDir := filepath.Join(repoPath, config.REPO_DIR, config.OBJECTS_DIR)
+	entries, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			if isHexString(entry.Name()) {
+				hashes = append(hashes, entry.Name())
+			}
+			continue
+		}
+		if len(entry.Name()) != 2 || !isHexString(entry.Name()) {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(objectsDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !f.IsDir() && isHexString(f.Name()) {
+				hashes = append(hashes, entry.Name()+f.Name())
+			}
+		}
+	}
+	return hashes, nil
+}
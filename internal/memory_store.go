@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type memoryObject struct {
+	Type string
+	Data []byte
+}
+
+// MemoryObjectStore keeps objects in memory, useful for tests and
+// for embedding jit without touching the disk
+type MemoryObjectStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+func NewMemoryObjectStore() *MemoryObjectStore {
+	return &MemoryObjectStore{objects: make(map[string]memoryObject)}
+}
+
+func (s *MemoryObjectStore) Get(hash string) (string, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[hash]
+	if !ok {
+		return "", nil, fmt.Errorf("failed to read object %s: %w", hash, ErrObjectNotFound)
+	}
+	// callers must not be able to modify stored objects
+	return obj.Type, append([]byte(nil), obj.Data...), nil
+}
+
+func (s *MemoryObjectStore) Put(typ string, data []byte) (string, error) {
+	hash := hashObject(typ, data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.objects[hash]; !ok {
+		s.objects[hash] = memoryObject{Type: typ, Data: append([]byte(nil), data...)}
+	}
+	return hash, nil
+}
+
+func (s *MemoryObjectStore) Has(hash string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.objects[hash]
+	return ok
+}
+
+func (s *MemoryObjectStore) Iterate(fn func(hash string) error) error {
+	s.mu.RLock()
+	hashes := make([]string, 0, len(s.objects))
+	for hash := range s.objects {
+		hashes = append(hashes, hash)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
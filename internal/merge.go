@@ -110,7 +110,11 @@ func MergeCommits(targetBranch string) error {
 	}
 
 	mergeMessage := fmt.Sprintf("Merged branch %s into HEAD", targetBranch)
-	_, err = CreateCommit(mergeMessage, time.Now(), &targetCommitHash)
+	// merge commits have 2 parents
+	_, err = CreateCommit(mergeMessage, time.Now(), CommitOptions{
+		Parents: []string{headCommitHash, targetCommitHash},
+		OldHead: headCommitHash,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create merge commit: %w", err)
 	}
@@ -0,0 +1,263 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/config"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// objectMigrator rewrites untyped, uncompressed objects into the
+// current object format. Object ids change in the process, so it
+// remembers the new id of every converted object.
+type objectMigrator struct {
+	repoPath  string
+	store     *LooseObjectStore
+	legacy    map[string]bool
+	converted map[string]string
+}
+
+// MigrateObjects converts every object written by older versions of jit
+// (raw bytes named by their plain hash) to typed, compressed objects.
+// Refs and the index are rewritten to the new ids.
+// Objects stored in the flat objects directory are moved into their
+// fan-out subdirectory.
+// Returns the number of converted objects
+func MigrateObjects(repoPath string) (int, error) {
+	objectsDir := filepath.Join(repoPath, config.REPO_DIR, config.OBJECTS_DIR)
+	files, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	store := NewLooseObjectStore(repoPath)
+	m := &objectMigrator{
+		repoPath:  repoPath,
+		store:     store,
+		legacy:    make(map[string]bool),
+		converted: make(map[string]string),
+	}
+	moved := 0
+	for _, file := range files {
+		// temporary files left behind by an interrupted write are not objects
+		if file.IsDir() || !isHexString(file.Name()) {
+			continue
+		}
+		hash := file.Name()
+		if _, _, err := store.Get(hash); err != nil {
+			m.legacy[hash] = true
+			continue
+		}
+
+		// already in the current format, only the location changes
+		dst := objectPath(repoPath, hash)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return 0, fmt.Errorf("failed to move object %s: %w", hash, err)
+		}
+		if err := os.Rename(flatObjectPath(repoPath, hash), dst); err != nil {
+			return 0, fmt.Errorf("failed to move object %s: %w", hash, err)
+		}
+		moved++
+	}
+	if len(m.legacy) == 0 {
+		return moved, nil
+	}
+
+	// commits reachable from refs, along with their trees and blobs
+	if err := m.migrateRefs(); err != nil {
+		return 0, err
+	}
+	if err := m.migrateIndex(); err != nil {
+		return 0, err
+	}
+
+	// whatever is left is unreachable, its type is unknown so keep it as a blob
+	for hash := range m.legacy {
+		if _, err := m.convertBlob(hash); err != nil {
+			return 0, err
+		}
+	}
+
+	for hash := range m.legacy {
+		if m.converted[hash] == hash {
+			continue
+		}
+		if err := os.Remove(flatObjectPath(repoPath, hash)); err != nil {
+			return 0, fmt.Errorf("failed to remove old object %s: %w", hash, err)
+		}
+	}
+
+	return moved + len(m.legacy), nil
+}
+
+// migrateRefs rewrites every branch ref and a detached HEAD
+func (m *objectMigrator) migrateRefs() error {
+	refsDir := filepath.Join(m.repoPath, config.REPO_DIR, config.REFS_DIR)
+	err := filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isRefName(d.Name()) {
+			return nil
+		}
+		return m.migrateRefFile(path)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate refs: %w", err)
+	}
+
+	headPath := filepath.Join(m.repoPath, config.REPO_DIR, config.HEAD_PATH)
+	head, err := os.ReadFile(headPath)
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(string(head)), "ref:") {
+		return m.migrateRefFile(headPath)
+	}
+	return nil
+}
+
+func (m *objectMigrator) migrateRefFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	oldHash := strings.TrimSpace(string(data))
+	if oldHash == "" {
+		return nil
+	}
+
+	newHash, err := m.convertCommit(oldHash)
+	if err != nil {
+		return err
+	}
+	return writeRef(path, newHash)
+}
+
+// migrateIndex rewrites the blob ids of staged files
+func (m *objectMigrator) migrateIndex() error {
+	indexPath := filepath.Join(m.repoPath, config.REPO_DIR, indexName)
+	lock, err := acquireLock(indexPath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	index, _, err := readIndexFile(m.repoPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	for i, entry := range *index {
+		if (*index)[i].Hash, err = m.convertBlob(entry.Hash); err != nil {
+			return err
+		}
+	}
+
+	return writeIndexFile(m.repoPath, index)
+}
+
+func (m *objectMigrator) readLegacy(hash string) ([]byte, error) {
+	return os.ReadFile(flatObjectPath(m.repoPath, hash))
+}
+
+func (m *objectMigrator) convertCommit(hash string) (string, error) {
+	if newHash, ok := m.converted[hash]; ok {
+		return newHash, nil
+	}
+	if !m.legacy[hash] {
+		return hash, nil
+	}
+
+	data, err := m.readLegacy(hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit %s: %w", hash, err)
+	}
+	commit, err := parseCommit(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse commit %s: %w", hash, err)
+	}
+
+	if commit.TreeID, err = m.convertTree(commit.TreeID); err != nil {
+		return "", err
+	}
+	for i, parent := range commit.ParentIDs {
+		if commit.ParentIDs[i], err = m.convertCommit(parent); err != nil {
+			return "", err
+		}
+	}
+
+	newHash, err := m.store.Put(commitObject, commit.Serialize())
+	if err != nil {
+		return "", err
+	}
+	m.converted[hash] = newHash
+	return newHash, nil
+}
+
+func (m *objectMigrator) convertTree(hash string) (string, error) {
+	if newHash, ok := m.converted[hash]; ok {
+		return newHash, nil
+	}
+	if !m.legacy[hash] {
+		return hash, nil
+	}
+
+	data, err := m.readLegacy(hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tree %s: %w", hash, err)
+	}
+	entries, err := parseTreeEntries(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse tree %s: %w", hash, err)
+	}
+
+	for i, entry := range entries {
+		switch entry.Type {
+		case treeObject:
+			entries[i].Hash, err = m.convertTree(entry.Hash)
+		case blobObject:
+			entries[i].Hash, err = m.convertBlob(entry.Hash)
+		default:
+			err = fmt.Errorf("unknown type %s in tree %s", entry.Type, hash)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	tree := &Tree{Entries: entries}
+	newHash, err := m.store.Put(treeObject, tree.Serialize())
+	if err != nil {
+		return "", err
+	}
+	m.converted[hash] = newHash
+	return newHash, nil
+}
+
+func (m *objectMigrator) convertBlob(hash string) (string, error) {
+	if newHash, ok := m.converted[hash]; ok {
+		return newHash, nil
+	}
+	if !m.legacy[hash] {
+		return hash, nil
+	}
+
+	data, err := m.readLegacy(hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+
+	newHash, err := m.store.Put(blobObject, data)
+	if err != nil {
+		return "", err
+	}
+	m.converted[hash] = newHash
+	return newHash, nil
+}
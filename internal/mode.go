@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// file modes recorded in trees and the index
+const (
+	modeRegular    uint32 = 0o100644
+	modeExecutable uint32 = 0o100755
+	modeSymlink    uint32 = 0o120000
+	modeTree       uint32 = 0o040000
+)
+
+// windows has no executable bit, the staged mode of a file is kept
+var trustExecutableBit = runtime.GOOS != "windows"
+
+// modeFromInfo returns the mode the file described by <info> is recorded
+// with, <staged> is the mode the index has for it, 0 if none
+func modeFromInfo(info fs.FileInfo, staged uint32) uint32 {
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return modeSymlink
+	case !trustExecutableBit && (staged == modeRegular || staged == modeExecutable):
+		return staged
+	case info.Mode().Perm()&0o111 != 0:
+		return modeExecutable
+	default:
+		return modeRegular
+	}
+}
+
+// modeOrDefault returns <mode>, or the regular file mode
+// for entries recorded before modes were
+func modeOrDefault(mode uint32) uint32 {
+	if mode == 0 {
+		return modeRegular
+	}
+	return mode
+}
+
+// isValidMode reports whether <mode> may be recorded for an entry of <typ>
+func isValidMode(typ string, mode uint32) bool {
+	if typ == treeObject {
+		return mode == modeTree
+	}
+	return mode == modeRegular || mode == modeExecutable || mode == modeSymlink
+}
+
+// formatMode returns <mode> as the octal string trees record
+func formatMode(mode uint32) string {
+	return fmt.Sprintf("%06o", mode)
+}
+
+// parseMode parses an octal mode as trees record it
+func parseMode(s string) (uint32, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode '%s'", s)
+	}
+	return uint32(mode), nil
+}
+
+// setExecutable sets or clears the executable bits of <f> to match <mode>,
+// executable bits are only set where read bits are
+func setExecutable(f *os.File, mode uint32) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	perm := info.Mode().Perm()
+	want := perm &^ 0o111
+	if mode == modeExecutable {
+		want |= (perm & 0o444) >> 2
+	}
+	if want == perm {
+		return nil
+	}
+	return f.Chmod(want)
+}
+
+// modeFromStat returns the mode of a file staged with <st>
+// by an index that didn't record modes
+func modeFromStat(st FileStat) uint32 {
+	mode := fs.FileMode(st.Mode)
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return modeSymlink
+	case trustExecutableBit && mode.Perm()&0o111 != 0:
+		return modeExecutable
+	default:
+		return modeRegular
+	}
+}
+
+// readSymlink returns the target of the symlink at <path> with forward
+// slashes, <ok> is false when <path> is no symlink
+func readSymlink(path string) (target string, ok bool, err error) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return "", false, err
+	}
+	target, err = os.Readlink(path)
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.ToSlash(target), true, nil
+}
+
+// readWorkingFile returns the content of the file at <path>
+// as it is stored, the target for symlinks
+func readWorkingFile(path string) ([]byte, error) {
+	if target, ok, err := readSymlink(path); err != nil || ok {
+		return []byte(target), err
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/config"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MoveFile renames the staged file or directory <src> to <dst> in the
+// working directory and in the index. When <dst> is an existing directory
+// <src> is moved into it.
+// Returns the new path of <src>
+func MoveFile(src, dst string) (string, error) {
+	indexPath := filepath.Join(config.REPO_DIR, indexName)
+	lock, err := acquireLock(indexPath)
+	if err != nil {
+		return "", err
+	}
+	defer lock.release()
+
+	index, _, err := readIndexFile(".")
+	if errors.Is(err, fs.ErrNotExist) {
+		index = &Index{}
+	} else if err != nil {
+		return "", err
+	}
+
+	src, dst = indexPathOf(src), indexPathOf(dst)
+	if src == "." || strings.HasPrefix(src, "../") {
+		return "", fmt.Errorf("bad source '%s'", src)
+	}
+	if _, err := os.Lstat(src); err != nil {
+		return "", fmt.Errorf("bad source '%s': %w", src, err)
+	}
+	if info, err := os.Stat(dst); err == nil {
+		if !info.IsDir() {
+			return "", fmt.Errorf("destination '%s' already exists", dst)
+		}
+		dst = path.Join(dst, path.Base(src))
+		if _, err := os.Lstat(dst); err == nil {
+			return "", fmt.Errorf("destination '%s' already exists", dst)
+		}
+	}
+	if isUnder(dst, src) {
+		return "", fmt.Errorf("can't move '%s' into itself", src)
+	}
+
+	moved := false
+	for i, entry := range *index {
+		entryPath := indexPathOf(entry.Filepath)
+		if isUnder(entryPath, src) {
+			(*index)[i].Filepath = dst + strings.TrimPrefix(entryPath, src)
+			moved = true
+		} else if isUnder(entryPath, dst) {
+			return "", fmt.Errorf("destination '%s' is staged", entryPath)
+		}
+	}
+	if !moved {
+		return "", fmt.Errorf("'%s' is not staged", src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", fmt.Errorf("failed to create '%s': %w", filepath.Dir(dst), err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return "", fmt.Errorf("failed to move '%s': %w", src, err)
+	}
+	if err := writeIndexFile(".", index); err != nil {
+		// keep the working directory and the index in step
+		os.Rename(dst, src)
+		return "", fmt.Errorf("failed to save index: %w", err)
+	}
+	return dst, nil
+}
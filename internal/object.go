@@ -0,0 +1,310 @@
+package internal
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"jit/config"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// object types stored in the object database
+const (
+	blobObject   = "blob"
+	treeObject   = "tree"
+	commitObject = "commit"
+	// list of the blobs a large file was split into (see chunk.go)
+	chunkedObject = "chunked"
+)
+
+// encodeObject prepends the "<type> <size>\0" header to <data>
+func encodeObject(typ string, data []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %d\x00", typ, len(data))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// decodeObject splits an uncompressed object into its type and payload
+func decodeObject(raw []byte) (string, []byte, error) {
+	nul := bytes.IndexByte(raw, 0)
+	if nul < 0 {
+		return "", nil, errors.New("missing object header")
+	}
+
+	typ, size, err := parseObjectHeader(string(raw[:nul]))
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := raw[nul+1:]
+	if int64(len(data)) != size {
+		return "", nil, fmt.Errorf(
+			"object size mismatch: header says %d, got %d", size, len(data))
+	}
+
+	return typ, data, nil
+}
+
+// parseObjectHeader parses a "<type> <size>" object header
+func parseObjectHeader(header string) (string, int64, error) {
+	typ, sizeStr, found := strings.Cut(header, " ")
+	if !found {
+		return "", 0, fmt.Errorf("malformed object header '%s'", header)
+	}
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, fmt.Errorf("malformed object size in header '%s'", header)
+	}
+	return typ, size, nil
+}
+
+// objectPath returns the path of the object with <hash>, objects are
+// sharded by the first two characters of their hash: objects/ab/cdef...
+func objectPath(repoPath, hash string) string {
+	objectsDir := filepath.Join(repoPath, config.REPO_DIR, config.OBJECTS_DIR)
+	if len(hash) <= 2 {
+		return filepath.Join(objectsDir, hash)
+	}
+	return filepath.Join(objectsDir, hash[:2], hash[2:])
+}
+
+// flatObjectPath returns the path older repositories stored <hash> at
+func flatObjectPath(repoPath, hash string) string {
+	return filepath.Join(repoPath, config.REPO_DIR, config.OBJECTS_DIR, hash)
+}
+
+// findObjectPath returns the path the object with <hash> is stored at,
+// falling back to the flat layout of older repositories
+func findObjectPath(repoPath, hash string) (string, error) {
+	path := objectPath(repoPath, hash)
+	_, err := os.Stat(path)
+	if err == nil {
+		return path, nil
+	}
+	if _, flatErr := os.Stat(flatObjectPath(repoPath, hash)); flatErr == nil {
+		return flatObjectPath(repoPath, hash), nil
+	}
+	return "", err
+}
+
+// hashObject returns the id an object of type <typ> with <data> would have
+func hashObject(typ string, data []byte) string {
+	return ComputeHash(encodeObject(typ, data))
+}
+
+// writeObject stores <data> as an object of type <typ>
+// in the repository at <repoPath> and returns its hash
+func writeObject(repoPath, typ string, data []byte) (string, error) {
+	return objectStore(repoPath).Put(typ, data)
+}
+
+// hasObject reports whether the object with <hash> is stored
+func hasObject(repoPath, hash string) bool {
+	return objectStore(repoPath).Has(hash)
+}
+
+// readObject reads the object with <hash> from the repository at <repoPath>
+// Returns the object type and its payload
+func readObject(repoPath, hash string) (string, []byte, error) {
+	return objectStore(repoPath).Get(hash)
+}
+
+// readObjectOfType reads the object with <hash> and checks that it is a <typ>
+func readObjectOfType(repoPath, hash, typ string) ([]byte, error) {
+	actual, data, err := readObject(repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	if actual != typ {
+		return nil, fmt.Errorf("object %s is a %s, not a %s", hash, actual, typ)
+	}
+	return data, nil
+}
+
+// inflateObject decompresses a stored object and decodes its header
+func inflateObject(compressed []byte) (string, []byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return decodeObject(raw)
+}
+
+// hashBlobFile returns the id the file at <path> has as a blob,
+// streaming its content through the hash function.
+// Files above the chunk threshold are hashed as chunked objects,
+// symlinks as their target
+func hashBlobFile(path string) (string, error) {
+	if target, ok, err := readSymlink(path); err != nil || ok {
+		return hashObject(blobObject, []byte(target)), err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if shouldChunk(".", info.Size()) {
+		return chunkFile(path, false)
+	}
+
+	h := newHasher(".")
+	fmt.Fprintf(h, "%s %d\x00", blobObject, info.Size())
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	if n != info.Size() {
+		return "", fmt.Errorf("'%s' changed while it was being read", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeBlobFile stores the file at <path> as a blob, or as chunks when it
+// is large, without loading it into memory and returns the blob id
+func writeBlobFile(path string) (string, error) {
+	if target, ok, err := readSymlink(path); err != nil || ok {
+		if err != nil {
+			return "", err
+		}
+		return writeObject(".", blobObject, []byte(target))
+	}
+
+	// hashing first avoids compressing files that are already stored
+	hash, err := hashBlobFile(path)
+	if err != nil {
+		return "", err
+	}
+	if hasObject(".", hash) {
+		return hash, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if shouldChunk(".", info.Size()) {
+		written, err := chunkFile(path, true)
+		if err == nil && written != hash {
+			err = fmt.Errorf("'%s' changed while it was being added", path)
+		}
+		return written, err
+	}
+
+	written, err := writeObjectStream(".", blobObject, info.Size(), f)
+	if err != nil {
+		return "", err
+	}
+	if written != hash {
+		return "", fmt.Errorf("'%s' changed while it was being added", path)
+	}
+	return hash, nil
+}
+
+// writeBlob stores <data> as a blob, or as chunks when it is large,
+// and returns the blob id
+func writeBlob(data []byte) (string, error) {
+	if shouldChunk(".", int64(len(data))) {
+		return chunkContent(bytes.NewReader(data), true)
+	}
+	return writeObject(".", blobObject, data)
+}
+
+// extractBlobFile writes the blob with <hash> from the repository at
+// <repoPath> to the file at <path> with <mode>, streaming its content.
+// Symlinks are created pointing to the target the blob holds
+func extractBlobFile(repoPath, hash, path string, mode uint32) error {
+	r, err := openBlob(repoPath, hash)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// a link in place of the file would be written through
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	if mode == modeSymlink {
+		target, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return os.Symlink(filepath.FromSlash(string(target)), path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := setExecutable(f, modeOrDefault(mode)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sizedReader reads exactly <remaining> bytes from <r>,
+// a shorter stream is an error rather than a silent EOF
+type sizedReader struct {
+	r         io.Reader
+	remaining int64
+	closers   []io.Closer
+}
+
+func (s *sizedReader) Read(p []byte) (int, error) {
+	if s.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.remaining {
+		p = p[:s.remaining]
+	}
+	n, err := s.r.Read(p)
+	s.remaining -= int64(n)
+	if err == io.EOF && s.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (s *sizedReader) Close() error {
+	var err error
+	for _, c := range s.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
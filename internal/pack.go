@@ -0,0 +1,707 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"jit/config"
+	"jit/fsutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Pack files bundle many objects into a single file.
+// format
+// "JPAK" <version uint32> <object count uint32>
+// <entry>...
+// <hash of everything above, raw bytes>
+//
+// entry
+// <kind byte> <payload size uvarint> [<distance to base entry uvarint>]
+// <zlib compressed payload>
+//
+// A delta entry's payload is a delta (see delta.go) against the entry
+// <distance> bytes before it in the same pack.
+//
+// Pack index files map object ids to entry offsets.
+// format
+// "JIDX" <version uint32> <hash size uint32> <object count uint32>
+// (<raw hash> <offset uint64>)... sorted by hash
+// <raw hash of the pack>
+const (
+	packMagic    = "JPAK"
+	packIdxMagic = "JIDX"
+	packVersion  = 1
+	packDirName  = "pack"
+
+	packKindCommit = 1
+	packKindTree   = 2
+	packKindBlob   = 3
+	packKindDelta  = 4
+	packKindChunks = 5
+
+	// candidates considered as delta base for each blob
+	packDeltaWindow = 10
+	// longest allowed chain of deltas
+	packMaxDeltaDepth = 50
+	// blobs larger than this are stored whole
+	packMaxDeltaSize = 16 << 20
+)
+
+var packKinds = map[string]byte{
+	commitObject:  packKindCommit,
+	treeObject:    packKindTree,
+	blobObject:    packKindBlob,
+	chunkedObject: packKindChunks,
+}
+
+type packIndexEntry struct {
+	Hash   string
+	Offset int64
+}
+
+// packFile is an opened pack with its index loaded into memory
+type packFile struct {
+	Path    string
+	Entries []packIndexEntry
+}
+
+// packDir returns the directory packs of the repository at <repoPath> live in
+func packDir(repoPath string) string {
+	return filepath.Join(repoPath, config.REPO_DIR, config.OBJECTS_DIR, packDirName)
+}
+
+var (
+	packCacheMu sync.Mutex
+	packCache   = map[string]*packFile{}
+)
+
+// loadPacks returns every pack in the repository at <repoPath>
+// pack indexes are cached since packs are never modified once written
+func loadPacks(repoPath string) ([]*packFile, error) {
+	idxPaths, err := filepath.Glob(filepath.Join(packDir(repoPath), "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(idxPaths)
+
+	packCacheMu.Lock()
+	defer packCacheMu.Unlock()
+
+	var packs []*packFile
+	for _, idxPath := range idxPaths {
+		absPath, err := filepath.Abs(idxPath)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := packCache[absPath]; ok {
+			packs = append(packs, p)
+			continue
+		}
+
+		p, err := readPackIndex(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pack index %s: %w", idxPath, err)
+		}
+		packCache[absPath] = p
+		packs = append(packs, p)
+	}
+	return packs, nil
+}
+
+// readPackIndex loads the index at <idxPath>
+func readPackIndex(idxPath string) (*packFile, error) {
+	data, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != packIdxMagic {
+		return nil, errors.New("not a pack index")
+	}
+	var header struct {
+		Version  uint32
+		HashSize uint32
+		Count    uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Version != packVersion {
+		return nil, fmt.Errorf("unsupported pack index version %d", header.Version)
+	}
+
+	entries := make([]packIndexEntry, header.Count)
+	rawHash := make([]byte, header.HashSize)
+	for i := range entries {
+		var offset uint64
+		if _, err := io.ReadFull(r, rawHash); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.BigEndian, &offset); err != nil {
+			return nil, err
+		}
+		entries[i] = packIndexEntry{
+			Hash:   hex.EncodeToString(rawHash),
+			Offset: int64(offset),
+		}
+	}
+
+	return &packFile{
+		Path:    strings.TrimSuffix(idxPath, ".idx") + ".pack",
+		Entries: entries,
+	}, nil
+}
+
+// find returns the offset of <hash> in the pack
+func (p *packFile) find(hash string) (int64, bool) {
+	i := sort.Search(len(p.Entries), func(i int) bool {
+		return p.Entries[i].Hash >= hash
+	})
+	if i < len(p.Entries) && p.Entries[i].Hash == hash {
+		return p.Entries[i].Offset, true
+	}
+	return 0, false
+}
+
+// openEntry reads the header of the entry at <offset>
+// Returns its kind, payload size, the offset of its delta base
+// and a reader over the decompressed payload
+func (p *packFile) openEntry(f *os.File, offset int64) (byte, int64, int64, io.ReadCloser, error) {
+	r := bufio.NewReader(io.NewSectionReader(f, offset, 1<<62))
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	var baseOffset int64
+	if kind == packKindDelta {
+		distance, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, 0, 0, nil, err
+		}
+		baseOffset = offset - int64(distance)
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	return kind, int64(size), baseOffset, zr, nil
+}
+
+// readEntry reads and resolves the entry at <offset>
+func (p *packFile) readEntry(f *os.File, offset int64, depth int) (string, []byte, error) {
+	if depth > packMaxDeltaDepth {
+		return "", nil, errors.New("delta chain too long")
+	}
+
+	kind, size, baseOffset, zr, err := p.openEntry(f, offset)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(zr, payload); err != nil {
+		return "", nil, err
+	}
+
+	if kind == packKindDelta {
+		typ, base, err := p.readEntry(f, baseOffset, depth+1)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := applyDelta(base, payload)
+		return typ, data, err
+	}
+
+	typ, err := packKindType(kind)
+	return typ, payload, err
+}
+
+// entryType returns the object type of the entry at <offset>, following
+// delta bases without inflating any payload
+func (p *packFile) entryType(f *os.File, offset int64) (string, error) {
+	for depth := 0; depth <= packMaxDeltaDepth; depth++ {
+		// kind, size and distance fit in a few bytes
+		r := bufio.NewReaderSize(io.NewSectionReader(f, offset, 1<<62), 32)
+		kind, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if kind != packKindDelta {
+			return packKindType(kind)
+		}
+		if _, err := binary.ReadUvarint(r); err != nil {
+			return "", err
+		}
+		distance, err := binary.ReadUvarint(r)
+		if err != nil {
+			return "", err
+		}
+		offset -= int64(distance)
+	}
+	return "", errors.New("delta chain too long")
+}
+
+// packKindType returns the object type stored by entries of <kind>
+func packKindType(kind byte) (string, error) {
+	for typ, k := range packKinds {
+		if k == kind {
+			return typ, nil
+		}
+	}
+	return "", fmt.Errorf("unknown pack entry kind %d", kind)
+}
+
+// read returns the object with <hash> from the pack
+func (p *packFile) read(hash string) (string, []byte, error) {
+	offset, ok := p.find(hash)
+	if !ok {
+		return "", nil, os.ErrNotExist
+	}
+
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	return p.readEntry(f, offset, 0)
+}
+
+// open streams the object with <hash> from the pack,
+// deltas are small and resolved in memory
+func (p *packFile) open(hash string) (string, int64, io.ReadCloser, error) {
+	offset, ok := p.find(hash)
+	if !ok {
+		return "", 0, nil, os.ErrNotExist
+	}
+
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	kind, size, _, zr, err := p.openEntry(f, offset)
+	if err != nil {
+		f.Close()
+		return "", 0, nil, err
+	}
+
+	if kind == packKindDelta {
+		zr.Close()
+		defer f.Close()
+		typ, data, err := p.readEntry(f, offset, 0)
+		if err != nil {
+			return "", 0, nil, err
+		}
+		return typ, int64(len(data)), io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	typ, err := packKindType(kind)
+	if err != nil {
+		zr.Close()
+		f.Close()
+		return "", 0, nil, err
+	}
+	return typ, size, &sizedReader{r: zr, remaining: size, closers: []io.Closer{zr, f}}, nil
+}
+
+// readPackedObject looks <hash> up in every pack of the repository
+func readPackedObject(repoPath, hash string) (string, []byte, error) {
+	packs, err := loadPacks(repoPath)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, p := range packs {
+		if _, ok := p.find(hash); ok {
+			return p.read(hash)
+		}
+	}
+	return "", nil, os.ErrNotExist
+}
+
+// openPackedObject streams <hash> from the first pack containing it
+func openPackedObject(repoPath, hash string) (string, int64, io.ReadCloser, error) {
+	packs, err := loadPacks(repoPath)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	for _, p := range packs {
+		if _, ok := p.find(hash); ok {
+			return p.open(hash)
+		}
+	}
+	return "", 0, nil, os.ErrNotExist
+}
+
+// packedObjectType returns the type of <hash> from the first pack containing it
+func packedObjectType(repoPath, hash string) (string, error) {
+	packs, err := loadPacks(repoPath)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range packs {
+		if offset, ok := p.find(hash); ok {
+			f, err := os.Open(p.Path)
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+			return p.entryType(f, offset)
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// hasPackedObject reports whether any pack contains <hash>
+func hasPackedObject(repoPath, hash string) bool {
+	packs, err := loadPacks(repoPath)
+	if err != nil {
+		return false
+	}
+	for _, p := range packs {
+		if _, ok := p.find(hash); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// packObject is an object queued for writing into a pack
+type packObject struct {
+	Hash string
+	Type string
+	Size int64
+	Name string // file name hint used to find delta bases
+}
+
+// writePack writes <objects> into a new pack and index in the repository at
+// <repoPath>, delta compressing blobs against similar blobs.
+// Returns the path of the pack
+func writePack(repoPath string, objects []packObject) (string, error) {
+	store := NewLooseObjectStore(repoPath)
+
+	// commits and trees first, then blobs grouped by name and
+	// ordered largest first so that deltas mostly remove data
+	typeOrder := map[string]int{commitObject: 0, treeObject: 1, chunkedObject: 2, blobObject: 3}
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		if typeOrder[a.Type] != typeOrder[b.Type] {
+			return typeOrder[a.Type] < typeOrder[b.Type]
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Hash < b.Hash
+	})
+
+	if err := os.MkdirAll(packDir(repoPath), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(packDir(repoPath), "tmp-pack-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := newHasher(repoPath)
+	buffered := bufio.NewWriter(io.MultiWriter(tmp, hasher))
+	w := &countingWriter{w: buffered}
+
+	w.Write([]byte(packMagic))
+	binary.Write(w, binary.BigEndian, uint32(packVersion))
+	binary.Write(w, binary.BigEndian, uint32(len(objects)))
+
+	type windowEntry struct {
+		Offset int64
+		Data   []byte
+		Depth  int
+	}
+	var window []windowEntry
+	depths := make(map[int64]int)
+
+	index := make([]packIndexEntry, 0, len(objects))
+	for _, obj := range objects {
+		offset := w.n
+		index = append(index, packIndexEntry{Hash: obj.Hash, Offset: offset})
+
+		if obj.Size > packMaxDeltaSize {
+			// too large for deltas, stream it straight into the pack
+			if err := writePackEntryStream(w, store, obj); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		typ, data, err := store.Get(obj.Hash)
+		if err != nil {
+			return "", err
+		}
+
+		kind := packKinds[typ]
+		payload := data
+		var baseDistance int64
+		if typ == blobObject {
+			// pick the base giving the smallest delta
+			best := -1
+			for i, candidate := range window {
+				if candidate.Depth >= packMaxDeltaDepth {
+					continue
+				}
+				delta := createDelta(candidate.Data, data)
+				if len(delta) < len(payload) && len(delta) < len(data)/2 {
+					payload = delta
+					best = i
+				}
+			}
+			if best >= 0 {
+				kind = packKindDelta
+				baseDistance = offset - window[best].Offset
+				depths[offset] = window[best].Depth + 1
+			}
+
+			window = append(window, windowEntry{offset, data, depths[offset]})
+			if len(window) > packDeltaWindow {
+				window = window[1:]
+			}
+		}
+
+		var header bytes.Buffer
+		header.WriteByte(kind)
+		writeUvarint(&header, uint64(len(payload)))
+		if kind == packKindDelta {
+			writeUvarint(&header, uint64(baseDistance))
+		}
+		if _, err := w.Write(header.Bytes()); err != nil {
+			return "", err
+		}
+		zw := zlib.NewWriter(w)
+		zw.Write(payload)
+		if err := zw.Close(); err != nil {
+			return "", err
+		}
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return "", err
+	}
+	checksum := hasher.Sum(nil)
+	if _, err := tmp.Write(checksum); err != nil {
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	name := "pack-" + hex.EncodeToString(checksum)
+	packPath, err := filepath.Abs(filepath.Join(packDir(repoPath), name+".pack"))
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), packPath); err != nil {
+		return "", err
+	}
+	if err := fsutil.SyncDir(packDir(repoPath)); err != nil {
+		return "", err
+	}
+
+	// the index is written last, a pack is only visible once it exists
+	if err := writePackIndex(
+		strings.TrimSuffix(packPath, ".pack")+".idx", index, checksum); err != nil {
+		return "", err
+	}
+
+	return packPath, nil
+}
+
+// writePackEntryStream copies <obj> from <store> into a whole pack entry
+func writePackEntryStream(w io.Writer, store *LooseObjectStore, obj packObject) error {
+	typ, size, r, err := store.Open(obj.Hash)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var header bytes.Buffer
+	header.WriteByte(packKinds[typ])
+	writeUvarint(&header, uint64(size))
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	zw := zlib.NewWriter(w)
+	if _, err := io.Copy(zw, r); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writePackIndex writes the index of a pack to <idxPath>
+func writePackIndex(idxPath string, entries []packIndexEntry, checksum []byte) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Hash < entries[j].Hash
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(packIdxMagic)
+	hashSize := len(checksum)
+	binary.Write(&buf, binary.BigEndian, uint32(packVersion))
+	binary.Write(&buf, binary.BigEndian, uint32(hashSize))
+	binary.Write(&buf, binary.BigEndian, uint32(len(entries)))
+	for _, e := range entries {
+		raw, err := hex.DecodeString(e.Hash)
+		if err != nil || len(raw) != hashSize {
+			return fmt.Errorf("invalid object id '%s'", e.Hash)
+		}
+		buf.Write(raw)
+		binary.Write(&buf, binary.BigEndian, uint64(e.Offset))
+	}
+	buf.Write(checksum)
+
+	return fsutil.WriteFileAtomic(idxPath, buf.Bytes(), 0644)
+}
+
+// Repack moves every loose and packed object of the repository at
+// <repoPath> into a single new pack.
+// Returns the number of packed objects
+func Repack(repoPath string) (int, error) {
+	if err := checkDiskStore(); err != nil {
+		return 0, err
+	}
+	return repack(repoPath, func(string) bool { return true })
+}
+
+// repack writes the loose and packed objects selected by <include> into a
+// single new pack. Old packs are removed, objects they hold that are not
+// included are dropped. Loose objects that are not included are left alone.
+// Returns the number of packed objects
+func repack(repoPath string, include func(hash string) bool) (int, error) {
+	loose, err := looseObjectHashes(repoPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list loose objects: %w", err)
+	}
+	oldPacks, err := loadPacks(repoPath)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool)
+	var hashes, packedLoose []string
+	for _, hash := range loose {
+		if !seen[hash] && include(hash) {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+			packedLoose = append(packedLoose, hash)
+		}
+	}
+	for _, p := range oldPacks {
+		for _, e := range p.Entries {
+			if !seen[e.Hash] && include(e.Hash) {
+				seen[e.Hash] = true
+				hashes = append(hashes, e.Hash)
+			}
+		}
+	}
+	if len(hashes) == 0 && len(oldPacks) == 0 {
+		return 0, nil
+	}
+
+	packPath := ""
+	if len(hashes) > 0 {
+		objects, err := describePackObjects(repoPath, hashes)
+		if err != nil {
+			return 0, err
+		}
+		packPath, err = writePack(repoPath, objects)
+		if err != nil {
+			return 0, fmt.Errorf("failed to write pack: %w", err)
+		}
+	}
+
+	// everything is safely packed now, drop the old copies
+	for _, p := range oldPacks {
+		if p.Path == packPath {
+			continue
+		}
+		os.Remove(strings.TrimSuffix(p.Path, ".pack") + ".idx")
+		os.Remove(p.Path)
+	}
+	for _, hash := range packedLoose {
+		removeLooseObject(repoPath, hash)
+	}
+
+	packCacheMu.Lock()
+	packCache = map[string]*packFile{}
+	packCacheMu.Unlock()
+
+	return len(hashes), nil
+}
+
+// describePackObjects reads the type and size of <hashes> and
+// names blobs after the tree entries pointing to them
+func describePackObjects(repoPath string, hashes []string) ([]packObject, error) {
+	store := NewLooseObjectStore(repoPath)
+	objects := make([]packObject, 0, len(hashes))
+	names := make(map[string]string)
+	for _, hash := range hashes {
+		// only the header is needed, blobs can be huge
+		typ, size, r, err := store.Open(hash)
+		if err != nil {
+			return nil, err
+		}
+		if typ == treeObject {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				r.Close()
+				return nil, fmt.Errorf("failed to read tree %s: %w", hash, err)
+			}
+			entries, err := parseTreeEntries(data)
+			if err != nil {
+				r.Close()
+				return nil, fmt.Errorf("failed to parse tree %s: %w", hash, err)
+			}
+			for _, e := range entries {
+				names[e.Hash] = e.Name
+			}
+		}
+		r.Close()
+		objects = append(objects, packObject{Hash: hash, Type: typ, Size: size})
+	}
+	for i := range objects {
+		objects[i].Name = names[objects[i].Hash]
+	}
+	return objects, nil
+}
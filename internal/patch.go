@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/config"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mrk21/go-diff-fmt/difffmt"
+)
+
+// lines of context around the changes of a hunk
+const hunkContext = 3
+
+// Hunk is a group of changed lines of a file and their context
+type Hunk struct {
+	Lines []difffmt.LineDiff
+	// first line of the hunk in the staged file, 1-based
+	OldStart int
+}
+
+// FilePatch holds the differences between the staged and
+// the working version of a file
+type FilePatch struct {
+	Path  string
+	Hunks []Hunk
+	// staged blob the hunks apply to
+	stagedHash    string
+	stagedContent string
+}
+
+// oldCount returns the number of staged lines the hunk covers
+func (h Hunk) oldCount() int {
+	count := 0
+	for _, line := range h.Lines {
+		if line.Operation != difffmt.OperationInsert {
+			count++
+		}
+	}
+	return count
+}
+
+// String formats the hunk in unified diff format
+func (h Hunk) String() string {
+	var b strings.Builder
+	newCount := 0
+	for _, line := range h.Lines {
+		if line.Operation != difffmt.OperationDelete {
+			newCount++
+		}
+	}
+	fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.oldCount(), h.newStart(), newCount)
+
+	for _, line := range h.Lines {
+		switch line.Operation {
+		case difffmt.OperationInsert:
+			b.WriteString("+")
+		case difffmt.OperationDelete:
+			b.WriteString("-")
+		default:
+			b.WriteString(" ")
+		}
+		b.WriteString(line.Text)
+		if !strings.HasSuffix(line.Text, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return b.String()
+}
+
+// newStart returns the first line of the hunk in the working file
+func (h Hunk) newStart() int {
+	if len(h.Lines) == 0 {
+		return h.OldStart
+	}
+	first := h.Lines[0]
+	if first.Operation == difffmt.OperationDelete {
+		return first.NewLine + 1
+	}
+	return first.NewLine
+}
+
+// CanSplit reports whether the hunk holds changes separated by context
+func (h Hunk) CanSplit() bool {
+	return len(h.Split()) > 1
+}
+
+// Split breaks the hunk into hunks holding one run of changed lines each,
+// the context between two runs leads the second one
+func (h Hunk) Split() []Hunk {
+	var hunks []Hunk
+	start := 0
+	oldLine := h.OldStart
+	for i := 1; i < len(h.Lines); i++ {
+		prev, curr := h.Lines[i-1], h.Lines[i]
+		if prev.Operation == difffmt.OperationEqual || curr.Operation != difffmt.OperationEqual {
+			continue
+		}
+		// a run of changes ends at <i>, split before the next one
+		next := i
+		for next < len(h.Lines) && h.Lines[next].Operation == difffmt.OperationEqual {
+			next++
+		}
+		if next == len(h.Lines) {
+			break
+		}
+		part := Hunk{Lines: h.Lines[start:i], OldStart: oldLine}
+		hunks = append(hunks, part)
+		oldLine += part.oldCount()
+		start = i
+		i = next
+	}
+	return append(hunks, Hunk{Lines: h.Lines[start:], OldStart: oldLine})
+}
+
+// ParseHunk reads <text>, hunk <h> in unified diff format after the user
+// edited it. Lines starting with '#' are comments.
+// The staged lines of the edited hunk must not change
+func ParseHunk(h Hunk, text string) (Hunk, error) {
+	edited := Hunk{OldStart: h.OldStart}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "@@") {
+			continue
+		}
+		if strings.HasPrefix(line, "\\") {
+			// "\ No newline at end of file" belongs to the line before
+			if n := len(edited.Lines); n > 0 {
+				edited.Lines[n-1].Text = strings.TrimSuffix(edited.Lines[n-1].Text, "\n")
+			}
+			continue
+		}
+
+		operation := difffmt.OperationEqual
+		switch line[0] {
+		case '+':
+			operation = difffmt.OperationInsert
+		case '-':
+			operation = difffmt.OperationDelete
+		case ' ':
+		case '\n':
+			// editors may strip the space of empty context lines
+			line = " " + line
+		default:
+			return Hunk{}, fmt.Errorf("invalid hunk line: %q", strings.TrimSuffix(line, "\n"))
+		}
+		edited.Lines = append(edited.Lines, difffmt.LineDiff{Operation: operation, Text: line[1:]})
+	}
+
+	if edited.oldSide() != h.oldSide() {
+		return Hunk{}, errors.New("edited hunk changes lines that are not staged")
+	}
+	return edited, nil
+}
+
+// oldSide returns the staged lines covered by <h>
+func (h Hunk) oldSide() string {
+	return h.side(difffmt.OperationInsert)
+}
+
+// newSide returns the lines <h> replaces its staged lines with
+func (h Hunk) newSide() string {
+	return h.side(difffmt.OperationDelete)
+}
+
+// side returns the lines of the hunk except those of <skip>
+func (h Hunk) side(skip difffmt.Operation) string {
+	var b strings.Builder
+	for _, line := range h.Lines {
+		if line.Operation != skip {
+			b.WriteString(line.Text)
+		}
+	}
+	return b.String()
+}
+
+// DiffWorkingFile returns the differences between the staged and
+// the working version of the file at <path>
+func DiffWorkingFile(path string) (*FilePatch, error) {
+	path = indexPathOf(path)
+	index, err := loadIndex()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	var stagedHash string
+	if index != nil {
+		for _, entry := range *index {
+			if indexPathOf(entry.Filepath) == path {
+				stagedHash = entry.Hash
+			}
+		}
+	}
+	if stagedHash == "" {
+		return nil, fmt.Errorf("'%s' is not tracked", path)
+	}
+
+	staged, err := loadBlobContent(stagedHash)
+	if err != nil {
+		return nil, err
+	}
+	working, err := readWorkingFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := &FilePatch{Path: path, stagedHash: stagedHash, stagedContent: staged}
+	for _, h := range difffmt.MakeHunks(lineDiffs(staged, string(working)), hunkContext) {
+		first := h.Diffs[0]
+		oldStart := first.OldLine
+		if first.Operation == difffmt.OperationInsert {
+			oldStart++
+		}
+		patch.Hunks = append(patch.Hunks, Hunk{Lines: h.Diffs, OldStart: oldStart})
+	}
+	return patch, nil
+}
+
+// StageHunks applies <hunks> of <patch> to the staged version of the file
+// and stages the result, the working file is left alone
+func StageHunks(patch *FilePatch, hunks []Hunk) error {
+	content, err := applyHunks(patch.stagedContent, hunks)
+	if err != nil {
+		return fmt.Errorf("failed to apply hunks to '%s': %w", patch.Path, err)
+	}
+	hash, err := writeBlob([]byte(content))
+	if err != nil {
+		return fmt.Errorf("failed to store '%s': %w", patch.Path, err)
+	}
+
+	indexPath := filepath.Join(config.REPO_DIR, indexName)
+	lock, err := acquireLock(indexPath)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	index, _, err := readIndexFile(".")
+	if err != nil {
+		return err
+	}
+	for i, entry := range *index {
+		if indexPathOf(entry.Filepath) != patch.Path {
+			continue
+		}
+		if entry.Hash != patch.stagedHash {
+			return fmt.Errorf("'%s' was staged by another process", patch.Path)
+		}
+		// the staged blob no longer matches the working file,
+		// empty stat data makes sure it is hashed again
+		(*index)[i] = IndexEntry{Hash: hash, Filepath: entry.Filepath, Mode: entry.Mode}
+		return writeIndexFile(".", index)
+	}
+	return fmt.Errorf("'%s' is not tracked", patch.Path)
+}
+
+// applyHunks returns <content> with the changes of <hunks> made
+func applyHunks(content string, hunks []Hunk) (string, error) {
+	hunks = append([]Hunk{}, hunks...)
+	sort.SliceStable(hunks, func(i, j int) bool {
+		return hunks[i].OldStart < hunks[j].OldStart
+	})
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var b strings.Builder
+	next := 0 // index of the next line to copy
+	for _, h := range hunks {
+		start := h.OldStart - 1
+		end := start + h.oldCount()
+		if start < next || end > len(lines) {
+			return "", fmt.Errorf("hunk at line %d does not apply", h.OldStart)
+		}
+		if strings.Join(lines[start:end], "") != h.oldSide() {
+			return "", fmt.Errorf("hunk at line %d does not apply", h.OldStart)
+		}
+		b.WriteString(strings.Join(lines[next:start], ""))
+		b.WriteString(h.newSide())
+		next = end
+	}
+	b.WriteString(strings.Join(lines[next:], ""))
+	return b.String(), nil
+}
+
+// PatchFiles returns the tracked files below <paths>
+// whose working version differs from the staged one
+func PatchFiles(paths []string) ([]string, error) {
+	status, err := GetStatus()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, change := range status.Unstaged {
+		if change.Change != StatusModified {
+			continue
+		}
+		for _, path := range paths {
+			if isUnder(change.Path, indexPathOf(path)) {
+				files = append(files, change.Path)
+				break
+			}
+		}
+	}
+	return files, nil
+}
@@ -7,111 +7,30 @@ import (
 	"path/filepath"
 )
 
+// rebuildWorkingDirectory replaces the files of the commit with
+// <currentCommitHash> in the working directory with those of the commit
+// with <targetCommitHash>
 func rebuildWorkingDirectory(currentCommitHash, targetCommitHash string) error {
-	currCommit, err := LoadCommit(".", currentCommitHash)
+	currFiles, err := commitFiles(currentCommitHash)
 	if err != nil {
 		return fmt.Errorf(
 			"Error loading current commit '%s': %w", currentCommitHash, err)
 	}
-	targetCommit, err := LoadCommit(".", targetCommitHash)
+	targetFiles, err := commitFiles(targetCommitHash)
 	if err != nil {
 		return fmt.Errorf(
 			"Error loading target commit '%s': %w", targetCommitHash, err)
 	}
 
-	currTreeHash := currCommit.TreeID
-	targetTreeHash := targetCommit.TreeID
-
-	currTree, err := loadTree(currTreeHash)
-	if err != nil {
-		return fmt.Errorf(
-			"failed to load current tree %s: %w", currTreeHash, err)
-	}
-	targetTree, err := loadTree(targetTreeHash)
-	if err != nil {
-		return fmt.Errorf(
-			"failed to load target tree %s: %w", targetTreeHash, err)
-	}
-
-	err = updateWorkingDirectoryFromTrees(currTree, targetTree)
-	if err != nil {
+	if _, err := resetWorkingDirectory(currFiles, targetFiles); err != nil {
 		return fmt.Errorf("failed to update working directory: %w", err)
 	}
 	return nil
 }
 
-func updateWorkingDirectoryFromTrees(currentTree, targetTree *Tree) error {
-	// map for easy lookup
-	targetEntries := make(map[string]TreeEntry)
-	for _, entry := range targetTree.Entries {
-		targetEntries[entry.Name] = entry
-	}
-
-	// handle files in curr tree but not in target tree (delete)
-	for _, entry := range currentTree.Entries {
-		if _, exists := targetEntries[entry.Name]; !exists {
-			path := filepath.Join(".", entry.Name)
-			if entry.Type == "tree" {
-				err := os.RemoveAll(path)
-				if err != nil {
-					return fmt.Errorf("failed to remove directory '%s': %w", path, err)
-				}
-			} else {
-				err := os.Remove(path)
-				if err != nil {
-					return fmt.Errorf("failed to remove file '%s': %w", path, err)
-				}
-			}
-		}
-	}
-
-	// handle files in target tree (create or update)
-	currEntries := make(map[string]TreeEntry)
-	for _, entry := range currentTree.Entries {
-		currEntries[entry.Name] = entry
-	}
-
-	for _, entry := range targetTree.Entries {
-		path := filepath.Join(".", entry.Name)
-		if currentEntry, exists := currEntries[entry.Name]; exists {
-			// file exists in both trees, check if it needs updating
-			if currentEntry.Hash != entry.Hash {
-				err := extractBlob(entry.Hash, path)
-				if err != nil {
-					return fmt.Errorf("failed to update file '%s': %w", path, err)
-				}
-			}
-		} else {
-			// file or dir does not exist, create it
-			if entry.Type == "tree" {
-				err := os.MkdirAll(path, 0755)
-				if err != nil {
-					return fmt.Errorf("failed to create directory '%s': %w", path, err)
-				}
-				err = ExtractTree(".", entry.Hash, ".")
-				if err != nil {
-					return fmt.Errorf("failed to extract directory '%s': %w", path, err)
-				}
-			} else {
-				err := extractBlob(entry.Hash, path)
-				if err != nil {
-					return fmt.Errorf("failed to create file '%s': %w", path, err)
-				}
-			}
-		}
-	}
-	return nil
-}
-
-// extractBlob writes blob with hash to path
-func extractBlob(hash, path string) error {
-	blobPath := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, hash)
-	content, err := os.ReadFile(blobPath)
-	if err != nil {
-		return fmt.Errorf("failed to read blob '%s': %w", hash, err)
-	}
-
-	err = os.WriteFile(path, content, 0644)
+// extractBlob writes blob with hash to path with mode
+func extractBlob(hash, path string, mode uint32) error {
+	err := extractBlobFile(".", hash, path, mode)
 	if err != nil {
 		return fmt.Errorf("failed to write file '%s': %w", path, err)
 	}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/config"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// modes of ResetHEAD
+const (
+	ResetSoft  = "soft"
+	ResetMixed = "mixed"
+	ResetHard  = "hard"
+)
+
+// ResetHEAD moves the current branch, or a detached HEAD, to the commit
+// with <commitHash>. The mixed <mode> also resets the index to the commit's
+// tree, hard resets the tracked files of the working directory too.
+// Untracked files are left alone
+func ResetHEAD(commitHash, mode string) error {
+	_, head, err := resolveHEAD(".")
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	target, err := LoadCommit(".", commitHash)
+	if err != nil {
+		return fmt.Errorf("failed to load commit %s: %w", commitHash, err)
+	}
+
+	var tracked map[string]TreeEntry
+	if mode == ResetHard {
+		// files to delete if the target commit doesn't have them
+		if tracked, err = commitFiles(head); err != nil {
+			return err
+		}
+		index, err := loadIndex()
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to load index: %w", err)
+		}
+		for path, entry := range indexFiles(index) {
+			tracked[path] = entry
+		}
+	}
+
+	if err := updateHEADCommitHash(head, commitHash); err != nil {
+		return fmt.Errorf("failed to update HEAD: %w", err)
+	}
+
+	switch mode {
+	case ResetSoft:
+		return nil
+	case ResetHard:
+		files, err := commitFiles(commitHash)
+		if err != nil {
+			return err
+		}
+		if _, err := resetWorkingDirectory(tracked, files); err != nil {
+			return fmt.Errorf("failed to reset working directory: %w", err)
+		}
+	}
+	if err := updateIndexFromTree(target.TreeID, mode == ResetHard); err != nil {
+		return fmt.Errorf("failed to reset index: %w", err)
+	}
+	return nil
+}
+
+// ResetPaths sets the index entries of the files below <paths> back to
+// their version in HEAD, files HEAD doesn't have are unstaged.
+// Returns the paths whose index entry changed
+func ResetPaths(paths []string) ([]string, error) {
+	_, head, err := resolveHEAD(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	headFiles, err := commitFiles(head)
+	if err != nil {
+		return nil, err
+	}
+	return resetIndexPaths(paths, headFiles)
+}
+
+// resetIndexPaths sets the index entries of the files below <paths> to
+// <files>, path -> blob entry, unstaging the files that are not among them.
+// Returns the paths whose index entry changed
+func resetIndexPaths(paths []string, files map[string]TreeEntry) ([]string, error) {
+	paths = normalizePaths(paths)
+	indexPath := filepath.Join(config.REPO_DIR, indexName)
+	lock, err := acquireLock(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+
+	index, _, err := readIndexFile(".")
+	if errors.Is(err, fs.ErrNotExist) {
+		index = &Index{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	var reset Index
+	staged := indexFiles(index)
+	for _, entry := range *index {
+		path := indexPathOf(entry.Filepath)
+		if !matchesAny(path, paths) {
+			reset = append(reset, entry)
+			continue
+		}
+		file, keep := files[path]
+		if !keep {
+			changed = append(changed, path)
+			continue
+		}
+		if file.Hash != entry.Hash || file.Mode != modeOrDefault(entry.Mode) {
+			// stat data of the staged version doesn't describe this one
+			entry = IndexEntry{Hash: file.Hash, Filepath: path, Mode: file.Mode}
+			changed = append(changed, path)
+		}
+		reset = append(reset, entry)
+	}
+	for path, file := range files {
+		if _, ok := staged[path]; !ok && matchesAny(path, paths) {
+			reset = append(reset, IndexEntry{Hash: file.Hash, Filepath: path, Mode: file.Mode})
+			changed = append(changed, path)
+		}
+	}
+	if err := checkPathsMatch(paths, staged, files); err != nil {
+		return nil, err
+	}
+
+	if err := writeIndexFile(".", &reset); err != nil {
+		return nil, fmt.Errorf("failed to save index: %w", err)
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// normalizePaths returns <paths> in the form of index paths
+func normalizePaths(paths []string) []string {
+	normalized := make([]string, len(paths))
+	for i, path := range paths {
+		normalized[i] = indexPathOf(path)
+	}
+	return normalized
+}
+
+// matchesAny reports whether <path> is one of <paths> or below one of them
+func matchesAny(path string, paths []string) bool {
+	for _, p := range paths {
+		if isUnder(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPathsMatch returns an error for the first of <paths> that matches
+// no file of <fileSets>, each mapping paths to blob entries
+func checkPathsMatch(paths []string, fileSets ...map[string]TreeEntry) error {
+	for _, path := range paths {
+		found := false
+		for _, files := range fileSets {
+			for file := range files {
+				found = found || isUnder(file, path)
+			}
+		}
+		if !found {
+			return fmt.Errorf("pathspec '%s' did not match any files", path)
+		}
+	}
+	return nil
+}
+
+// resetWorkingDirectory makes the working directory hold <files>, path ->
+// blob entry, deleting the <tracked> files that are not among them.
+// Returns the paths of the files it wrote or deleted
+func resetWorkingDirectory(tracked, files map[string]TreeEntry) ([]string, error) {
+	var changed []string
+	for path := range tracked {
+		if _, keep := files[path]; keep {
+			continue
+		}
+		err := os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove '%s': %w", path, err)
+		}
+		removeEmptyDirs(filepath.Dir(path))
+		changed = append(changed, path)
+	}
+
+	for path, file := range files {
+		if info, err := os.Lstat(path); err == nil && modeFromInfo(info, file.Mode) == file.Mode {
+			if current, err := hashBlobFile(path); err == nil && current == file.Hash {
+				continue
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for '%s': %w", path, err)
+		}
+		if err := extractBlob(file.Hash, path, file.Mode); err != nil {
+			return nil, err
+		}
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// RestoreFiles writes the version of the files below <paths> from the
+// commit <source> back into the working directory, or into the index when
+// <staged> is set. Without a source the working directory is restored from
+// the index and the index from HEAD. Tracked files the source doesn't have
+// are removed.
+// Returns the restored paths
+func RestoreFiles(paths []string, source string, staged bool) ([]string, error) {
+	if source == "" && staged {
+		_, head, err := resolveHEAD(".")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HEAD: %w", err)
+		}
+		source = head
+	}
+
+	index, err := loadIndex()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	stagedFiles := indexFiles(index)
+
+	files := stagedFiles
+	if staged || source != "" {
+		files = make(map[string]TreeEntry)
+	}
+	if source != "" {
+		commit, err := LoadCommit(".", source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load commit %s: %w", source, err)
+		}
+		treeFiles, err := buildFileMapFromTree(commit.TreeID)
+		if err != nil {
+			return nil, err
+		}
+		for path, entry := range treeFiles {
+			files[filepath.ToSlash(path)] = entry
+		}
+	}
+
+	if staged {
+		return resetIndexPaths(paths, files)
+	}
+
+	paths = normalizePaths(paths)
+	if err := checkPathsMatch(paths, stagedFiles, files); err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]TreeEntry)
+	for path, entry := range stagedFiles {
+		if matchesAny(path, paths) {
+			tracked[path] = entry
+		}
+	}
+	restored := make(map[string]TreeEntry)
+	for path, entry := range files {
+		if matchesAny(path, paths) {
+			restored[path] = entry
+		}
+	}
+	return resetWorkingDirectory(tracked, restored)
+}
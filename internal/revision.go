@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"jit/config"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownRevision is returned by ResolveCommit for names that are
+// neither HEAD, a branch nor a known hash
+var ErrUnknownRevision = errors.New("unknown revision")
+
+// ResolveCommit returns the hash of the commit <rev> names: HEAD, a branch
+// or a commit hash, optionally followed by ~<n> or ^ to name an ancestor
+// along first parents, e.g. HEAD~2 or master^
+func ResolveCommit(rev string) (string, error) {
+	base := rev
+	suffix := ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		base, suffix = rev[:i], rev[i:]
+	}
+
+	hash, err := resolveName(base)
+	if err != nil {
+		return "", err
+	}
+	if _, err := LoadCommit(".", hash); err != nil {
+		return "", fmt.Errorf("'%s' is not a commit: %w", rev, err)
+	}
+
+	for suffix != "" {
+		steps := 1
+		op := suffix[0]
+		suffix = suffix[1:]
+		if op == '~' {
+			digits := len(suffix) - len(strings.TrimLeft(suffix, "0123456789"))
+			if digits > 0 {
+				steps, _ = strconv.Atoi(suffix[:digits])
+				suffix = suffix[digits:]
+			}
+		} else if op != '^' {
+			return "", fmt.Errorf("%w '%s'", ErrUnknownRevision, rev)
+		}
+
+		for ; steps > 0; steps-- {
+			commit, err := LoadCommit(".", hash)
+			if err != nil {
+				return "", err
+			}
+			if len(commit.ParentIDs) == 0 {
+				return "", fmt.Errorf("'%s' goes past the first commit", rev)
+			}
+			hash = commit.ParentIDs[0]
+		}
+	}
+	return hash, nil
+}
+
+// resolveName returns the commit HEAD, the branch or the hash <name> points to
+func resolveName(name string) (string, error) {
+	if name == "HEAD" {
+		_, head, err := resolveHEAD(".")
+		if err != nil {
+			return "", fmt.Errorf("failed to read HEAD: %w", err)
+		}
+		if head == "" {
+			return "", fmt.Errorf("HEAD does not point to a commit yet")
+		}
+		return head, nil
+	}
+
+	if IsBranchName(name) {
+		hash, err := readRef(filepath.Join(config.REPO_DIR, config.REFS_DIR, "heads", name))
+		if err != nil {
+			return "", err
+		}
+		if hash != "" {
+			return hash, nil
+		}
+	}
+
+	if isValidHash(name) && hasObject(".", name) {
+		return name, nil
+	}
+	return "", fmt.Errorf("%w '%s'", ErrUnknownRevision, name)
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/config"
+	"os"
+	"path/filepath"
+)
+
+// RemoveFromIndex unstages the files matching <paths>, a directory matches
+// every staged file below it. Unless <cached> is set the files are deleted
+// from the working directory too. Changes that are not committed are never
+// dropped unless <force> is set.
+// Returns the removed paths
+func RemoveFromIndex(paths []string, cached, force bool) ([]string, error) {
+	indexPath := filepath.Join(config.REPO_DIR, indexName)
+	lock, err := acquireLock(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+
+	index, indexTime, err := readIndexFile(".")
+	if errors.Is(err, fs.ErrNotExist) {
+		index = &Index{}
+	} else if err != nil {
+		return nil, err
+	}
+	_, head, err := resolveHEAD(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	headFiles, err := commitFiles(head)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make(map[int]bool)
+	for _, path := range paths {
+		found := false
+		for i, entry := range *index {
+			if isUnder(entry.Filepath, path) {
+				matched[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("pathspec '%s' did not match any staged files", path)
+		}
+	}
+
+	var kept Index
+	var removed []string
+	for i, entry := range *index {
+		if !matched[i] {
+			kept = append(kept, entry)
+			continue
+		}
+		if !force {
+			if err := checkRemovable(entry, headFiles, indexTime, cached); err != nil {
+				return nil, err
+			}
+		}
+		removed = append(removed, entry.Filepath)
+	}
+
+	if err := writeIndexFile(".", &kept); err != nil {
+		return nil, fmt.Errorf("failed to save index: %w", err)
+	}
+	if cached {
+		return removed, nil
+	}
+
+	for _, path := range removed {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove '%s': %w", path, err)
+		}
+		removeEmptyDirs(filepath.Dir(path))
+	}
+	return removed, nil
+}
+
+// checkRemovable returns an error when removing <entry> would lose
+// content that is neither committed nor kept in the working directory
+func checkRemovable(entry IndexEntry, headFiles map[string]TreeEntry, indexTime int64, cached bool) error {
+	head, committed := headFiles[indexPathOf(entry.Filepath)]
+	stagedChanges := !committed || head.Hash != entry.Hash ||
+		modeOrDefault(head.Mode) != modeOrDefault(entry.Mode)
+	localChanges, err := hasLocalChanges(entry, indexTime)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cached && stagedChanges && localChanges:
+		return fmt.Errorf(
+			"'%s' has staged content different from both the file and HEAD, use -f to remove it anyway",
+			entry.Filepath)
+	case !cached && localChanges:
+		return fmt.Errorf(
+			"'%s' has local modifications, use --cached to keep the file or -f to remove it anyway",
+			entry.Filepath)
+	case !cached && stagedChanges:
+		return fmt.Errorf(
+			"'%s' has changes staged in the index, use --cached to keep the file or -f to remove it anyway",
+			entry.Filepath)
+	}
+	return nil
+}
+
+// hasLocalChanges reports whether the working file of <entry> differs
+// from its staged content, a missing file has nothing to lose
+func hasLocalChanges(entry IndexEntry, indexTime int64) (bool, error) {
+	info, err := os.Lstat(entry.Filepath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if entry.isUnchanged(info, indexTime) {
+		return false, nil
+	}
+
+	hash, err := hashBlobFile(entry.Filepath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file '%s': %w", entry.Filepath, err)
+	}
+	return hash != entry.Hash, nil
+}
+
+// removeEmptyDirs removes <dir> and its parents up to the
+// working directory root for as long as they are empty
+func removeEmptyDirs(dir string) {
+	for dir != "." && dir != string(filepath.Separator) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"jit/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	repoConfigsMu sync.Mutex
+	// absolute repository path -> settings from .jit/config
+	repoConfigs = map[string]config.Values{}
+)
+
+// repoSetting returns the value of <key> in the config of the repository
+// at <repoPath>, "" when unset. Configs are read once per process.
+func repoSetting(repoPath, key string) string {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return ""
+	}
+
+	repoConfigsMu.Lock()
+	defer repoConfigsMu.Unlock()
+	values, ok := repoConfigs[absPath]
+	if !ok {
+		values, err = config.ReadFile(filepath.Join(absPath, config.REPO_DIR, config.CONFIG_PATH))
+		if err != nil {
+			values = config.Values{}
+		}
+		repoConfigs[absPath] = values
+	}
+	return values[key]
+}
+
+// userSetting returns the value of <key> in the user config, "" when unset
+func userSetting(key string) string {
+	path, err := config.UserConfigFile()
+	if err != nil {
+		return ""
+	}
+	values, err := config.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return values[key]
+}
+
+// envSettings returns the settings overridden by JIT_CONFIG_* variables
+func envSettings() config.Values {
+	values := config.Values{}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, config.ENV_PREFIX) || value == "" {
+			continue
+		}
+		// the first underscore separates the section from the name
+		key := strings.ToLower(strings.Replace(strings.TrimPrefix(name, config.ENV_PREFIX), "_", ".", 1))
+		if config.CheckKey(key) == nil {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// setting returns the value of <key> for the repository at <repoPath>,
+// the environment takes precedence over its config,
+// which takes precedence over the user config
+func setting(repoPath, key string) string {
+	if value := os.Getenv(config.EnvName(key)); value != "" {
+		return value
+	}
+	if value := repoSetting(repoPath, key); value != "" {
+		return value
+	}
+	return userSetting(key)
+}
+
+// setRepoSetting sets <key> to <value> in the config of the repository at <repoPath>
+func setRepoSetting(repoPath, key, value string) error {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return err
+	}
+
+	repoConfigsMu.Lock()
+	defer repoConfigsMu.Unlock()
+	configPath := filepath.Join(absPath, config.REPO_DIR, config.CONFIG_PATH)
+	values, err := config.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	values[key] = value
+	if err := config.WriteFile(configPath, values); err != nil {
+		return err
+	}
+	repoConfigs[absPath] = values
+	return nil
+}
+
+// unsetRepoSetting removes <key> from the config of the repository at <repoPath>
+func unsetRepoSetting(repoPath, key string) error {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return err
+	}
+
+	repoConfigsMu.Lock()
+	defer repoConfigsMu.Unlock()
+	configPath := filepath.Join(absPath, config.REPO_DIR, config.CONFIG_PATH)
+	values, err := config.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	delete(values, key)
+	if err := config.WriteFile(configPath, values); err != nil {
+		return err
+	}
+	repoConfigs[absPath] = values
+	return nil
+}
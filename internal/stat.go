@@ -0,0 +1,24 @@
+package internal
+
+import "io/fs"
+
+// FileStat is the stat data the index records for a file, a file whose
+// stat data still matches was not modified and needs no rehashing
+type FileStat struct {
+	CTime int64 // nanoseconds, 0 where unsupported
+	MTime int64 // nanoseconds
+	Size  int64
+	Inode uint64 // 0 where unsupported
+	Mode  uint32
+}
+
+// statFromInfo returns the stat data of <info>
+func statFromInfo(info fs.FileInfo) FileStat {
+	st := FileStat{
+		MTime: info.ModTime().UnixNano(),
+		Size:  info.Size(),
+		Mode:  uint32(info.Mode()),
+	}
+	fillSysStat(&st, info)
+	return st
+}
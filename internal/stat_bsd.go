@@ -0,0 +1,16 @@
+//go:build darwin || freebsd || netbsd
+
+package internal
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fillSysStat adds the change time and inode of <info> to <st>
+func fillSysStat(st *FileStat, info fs.FileInfo) {
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		st.CTime = sys.Ctimespec.Nano()
+		st.Inode = uint64(sys.Ino)
+	}
+}
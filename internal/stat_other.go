@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+
+package internal
+
+import "io/fs"
+
+// fillSysStat does nothing, change time and inode are not available
+// here and the modification time and size have to do
+func fillSysStat(st *FileStat, info fs.FileInfo) {}
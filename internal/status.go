@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"jit/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// kinds of changes reported by GetStatus
+const (
+	StatusNew      = "new"
+	StatusModified = "modified"
+	StatusDeleted  = "deleted"
+)
+
+type FileChange struct {
+	Path   string
+	Change string // new, modified or deleted
+}
+
+type Status struct {
+	// checked out branch, "" for a detached HEAD
+	Branch string
+	// HEAD commit, "" before the first commit
+	Head string
+	// changes between HEAD and the index
+	Staged []FileChange
+	// changes between the index and the working directory
+	Unstaged []FileChange
+	// files in the working directory that are neither staged nor ignored
+	Untracked []string
+}
+
+// Clean reports whether there is nothing to commit and nothing untracked
+func (s *Status) Clean() bool {
+	return len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0
+}
+
+// GetStatus compares HEAD's tree, the index and the working directory
+// of the current repository file by file
+func GetStatus() (*Status, error) {
+	status := &Status{}
+	var err error
+	status.Branch, status.Head, err = resolveHEAD(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	headFiles, err := commitFiles(status.Head)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := loadIndex()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	stagedFiles := indexFiles(index)
+
+	working, err := CreateFakeIndex(".")
+	if err != nil {
+		return nil, err
+	}
+	workingFiles := indexFiles(working)
+
+	for path, head := range headFiles {
+		if staged, ok := stagedFiles[path]; !ok {
+			status.Staged = append(status.Staged, FileChange{path, StatusDeleted})
+		} else if staged != head {
+			status.Staged = append(status.Staged, FileChange{path, StatusModified})
+		}
+	}
+	for path, staged := range stagedFiles {
+		if _, committed := headFiles[path]; !committed {
+			status.Staged = append(status.Staged, FileChange{path, StatusNew})
+		}
+
+		if working, exists := workingFiles[path]; !exists {
+			status.Unstaged = append(status.Unstaged, FileChange{path, StatusDeleted})
+		} else if working != staged {
+			status.Unstaged = append(status.Unstaged, FileChange{path, StatusModified})
+		}
+	}
+	for path := range workingFiles {
+		if _, staged := stagedFiles[path]; !staged {
+			status.Untracked = append(status.Untracked, path)
+		}
+	}
+
+	sortChanges(status.Staged)
+	sortChanges(status.Unstaged)
+	sort.Strings(status.Untracked)
+	return status, nil
+}
+
+// commitFiles returns path -> blob entry for every file of the commit with
+// <commitHash>, paths use forward slashes like index paths.
+// Returns an empty map for "", the parent of the first commit
+func commitFiles(commitHash string) (map[string]TreeEntry, error) {
+	files := make(map[string]TreeEntry)
+	if commitHash == "" {
+		return files, nil
+	}
+
+	commit, err := LoadCommit(".", commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load commit %s: %w", commitHash, err)
+	}
+	treeFiles := make(map[string]TreeEntry)
+	if err := walkTree("", commit.TreeID, treeFiles); err != nil {
+		return nil, err
+	}
+	for path, entry := range treeFiles {
+		entry.Name = filepath.ToSlash(path)
+		files[entry.Name] = entry
+	}
+	return files, nil
+}
+
+// indexFiles returns path -> blob entry for every file of <index>
+func indexFiles(index *Index) map[string]TreeEntry {
+	files := make(map[string]TreeEntry)
+	if index == nil {
+		return files
+	}
+	for _, entry := range *index {
+		path := indexPathOf(entry.Filepath)
+		files[path] = TreeEntry{Mode: modeOrDefault(entry.Mode), Type: blobObject, Name: path, Hash: entry.Hash}
+	}
+	return files
+}
+
+func sortChanges(changes []FileChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+}
+
+// resolveHEAD returns the branch HEAD of the repository at <repoPath>
+// points to ("" when detached) and its commit ("" before the first commit)
+func resolveHEAD(repoPath string) (string, string, error) {
+	data, err := os.ReadFile(filepath.Join(repoPath, config.REPO_DIR, config.HEAD_PATH))
+	if err != nil {
+		return "", "", err
+	}
+	head := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(head, "ref:") {
+		return "", head, nil
+	}
+
+	ref := strings.TrimSpace(strings.TrimPrefix(head, "ref:"))
+	commit, err := readRef(filepath.Join(repoPath, config.REPO_DIR, ref))
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimPrefix(ref, "refs/heads/"), commit, nil
+}
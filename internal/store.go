@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrObjectNotFound is returned by an ObjectStore for unknown hashes
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore is the storage backend of the object database.
+// Objects are immutable and addressed by the hash of their
+// "<type> <size>\0<data>" encoding.
+type ObjectStore interface {
+	// Get returns the type and content of the object with <hash>
+	Get(hash string) (string, []byte, error)
+	// Put stores <data> as an object of type <typ> and returns its hash
+	Put(typ string, data []byte) (string, error)
+	// Has reports whether the object with <hash> is stored
+	Has(hash string) bool
+	// Iterate calls <fn> with the hash of every stored object,
+	// stopping at the first error
+	Iterate(fn func(hash string) error) error
+}
+
+var (
+	storeMu       sync.RWMutex
+	storeOverride ObjectStore
+)
+
+// ErrNotOnDisk is returned by operations that rewrite the objects directory,
+// like packing and garbage collection, when another store is installed
+var ErrNotOnDisk = errors.New("the object store is not the objects directory")
+
+// SetObjectStore makes every repository use <store> instead of the
+// objects directory on disk. Passing nil restores the default.
+func SetObjectStore(store ObjectStore) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	storeOverride = store
+}
+
+// checkDiskStore returns ErrNotOnDisk when SetObjectStore installed a store
+func checkDiskStore() error {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	if storeOverride != nil {
+		return ErrNotOnDisk
+	}
+	return nil
+}
+
+// objectStore returns the object store of the repository at <repoPath>
+func objectStore(repoPath string) ObjectStore {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	if storeOverride != nil {
+		return storeOverride
+	}
+	return NewLooseObjectStore(repoPath)
+}
+
+// StreamingObjectStore is implemented by stores that can read and write
+// objects without holding them in memory
+type StreamingObjectStore interface {
+	ObjectStore
+	// Open returns the type and size of the object with <hash>
+	// and a reader over its content
+	Open(hash string) (string, int64, io.ReadCloser, error)
+	// PutStream stores <size> bytes read from <r> as an object of
+	// type <typ> and returns its hash
+	PutStream(typ string, size int64, r io.Reader) (string, error)
+}
+
+// openObject returns a reader over the object with <hash>, streaming
+// from the store when it supports it
+func openObject(repoPath, hash string) (string, int64, io.ReadCloser, error) {
+	store := objectStore(repoPath)
+	if s, ok := store.(StreamingObjectStore); ok {
+		return s.Open(hash)
+	}
+
+	typ, data, err := store.Get(hash)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return typ, int64(len(data)), io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// writeObjectStream stores <size> bytes read from <r> as an object of
+// type <typ>, streaming into the store when it supports it
+func writeObjectStream(repoPath, typ string, size int64, r io.Reader) (string, error) {
+	store := objectStore(repoPath)
+	if s, ok := store.(StreamingObjectStore); ok {
+		return s.PutStream(typ, size, r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, size+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) != size {
+		return "", fmt.Errorf("expected %d bytes, got %d", size, len(data))
+	}
+	return store.Put(typ, data)
+}
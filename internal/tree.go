@@ -12,6 +12,7 @@ import (
 )
 
 type TreeEntry struct {
+	Mode uint32 // see mode.go
 	Type string // blob or tree
 	Name string
 	Hash string // hash of blob or subtree
@@ -37,6 +38,7 @@ func BuildTreeFromIndex(files *Index) (*Tree, error) {
 		if len(parts) == 1 {
 			// in root
 			blobEntries = append(blobEntries, TreeEntry{
+				Mode: modeOrDefault(f.Mode),
 				Type: "blob",
 				Name: parts[0],
 				Hash: f.Hash,
@@ -49,6 +51,7 @@ func BuildTreeFromIndex(files *Index) (*Tree, error) {
 			rootEntriesMap[dirName] = append(rootEntriesMap[dirName], IndexEntry{
 				Filepath: remainingPath,
 				Hash:     f.Hash,
+				Mode:     f.Mode,
 			})
 		}
 	}
@@ -60,8 +63,11 @@ func BuildTreeFromIndex(files *Index) (*Tree, error) {
 		if err != nil {
 			return nil, err
 		}
-		subTree.Save()
+		if err := subTree.Save(); err != nil {
+			return nil, err
+		}
 		blobEntries = append(blobEntries, TreeEntry{
+			Mode: modeTree,
 			Type: "tree",
 			Name: dirName,
 			Hash: subTree.Hash,
@@ -73,72 +79,129 @@ func BuildTreeFromIndex(files *Index) (*Tree, error) {
 		return blobEntries[i].Name < blobEntries[j].Name
 	})
 
-	// tree data
-	var buf bytes.Buffer
-	for _, e := range blobEntries {
-		fmt.Fprintf(&buf, "%s %s %s\n", e.Type, e.Name, e.Hash)
-	}
-
-	// fmt.Println("Hashing tree")
-	// fmt.Println(buf.String())
-	// fmt.Println()
-	treeHash := ComputeHash(buf.Bytes())
-
 	t := &Tree{
-		Hash:    treeHash,
 		Entries: blobEntries,
 	}
+	t.Hash = hashObject(treeObject, t.Serialize())
 
 	return t, nil
 }
 
-// Save writes the tree object to the objects directory
-func (t *Tree) Save() error {
-	var sb strings.Builder
+func (t *Tree) Serialize() []byte {
+	// format, one line per entry
+	// [<mode> ]<type> <name> <hash>
+	// the mode is left out for regular files and trees,
+	// trees recorded before modes keep their ids
+	var buf bytes.Buffer
 	for _, e := range t.Entries {
-		sb.WriteString(fmt.Sprintf("%s %s %s\n", e.Type, e.Name, e.Hash))
+		mode := modeOrDefault(e.Mode)
+		if (e.Type == treeObject && mode != modeTree) || (e.Type != treeObject && mode != modeRegular) {
+			fmt.Fprintf(&buf, "%s ", formatMode(mode))
+		}
+		fmt.Fprintf(&buf, "%s %s %s\n", e.Type, e.Name, e.Hash)
 	}
-	return os.WriteFile(
-		filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, t.Hash),
-		[]byte(sb.String()), 0644,
-	)
+	return buf.Bytes()
 }
 
-var t *Tree = nil
-
-// loadTree returns a tree with <treeHash>
-// caches because trees are immutable
-func loadTree(treeHash string) (*Tree, error) {
-	if t != nil && t.Hash == treeHash {
-		return t, nil
-	}
-
-	treePath := filepath.Join(config.REPO_DIR, config.OBJECTS_DIR, treeHash)
-	content, err := os.ReadFile(treePath)
+// Save writes the tree object to the objects directory
+func (t *Tree) Save() error {
+	hash, err := writeObject(".", treeObject, t.Serialize())
 	if err != nil {
-		return nil, fmt.Errorf("failed to read tree '%s': %w", treeHash, err)
+		return err
 	}
+	t.Hash = hash
+	return nil
+}
 
+// parseTreeEntries parses a serialized tree, see Tree.Serialize for the format
+func parseTreeEntries(data []byte) ([]TreeEntry, error) {
 	var entries []TreeEntry
-	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
 	for _, line := range lines {
-		parts := strings.SplitN(line, " ", 3)
-		if len(parts) != 3 {
+		if line == "" {
+			continue
+		}
+		// names may hold spaces, the hash is the last field
+		sep := strings.LastIndex(line, " ")
+		if sep < 0 {
 			return nil, fmt.Errorf("malformed tree entry: '%s'", line)
 		}
-		entries = append(entries, TreeEntry{
-			Type: parts[0],
-			Name: parts[1],
-			Hash: parts[2],
-		})
+		entry := TreeEntry{Hash: line[sep+1:]}
+		first, rest, ok := strings.Cut(line[:sep], " ")
+		if !ok {
+			return nil, fmt.Errorf("malformed tree entry: '%s'", line)
+		}
+		if strings.Trim(first, "01234567") == "" {
+			mode, err := parseMode(first)
+			if err != nil {
+				return nil, err
+			}
+			entry.Mode = mode
+			if first, rest, ok = strings.Cut(rest, " "); !ok {
+				return nil, fmt.Errorf("malformed tree entry: '%s'", line)
+			}
+		} else if first == treeObject {
+			entry.Mode = modeTree
+		} else {
+			entry.Mode = modeRegular
+		}
+		entry.Type = first
+		entry.Name = rest
+		entries = append(entries, entry)
 	}
+	return entries, nil
+}
 
-	t = &Tree{
+// readTree reads the tree with <treeHash> from the repository at <repoPath>
+func readTree(repoPath, treeHash string) (*Tree, error) {
+	data, err := readObjectOfType(repoPath, treeHash, treeObject)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseTreeEntries(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tree '%s': %w", treeHash, err)
+	}
+
+	return &Tree{
 		Hash:    treeHash,
 		Entries: entries,
+	}, nil
+}
+
+// loadTree returns a tree with <treeHash>
+// caches because trees are immutable, callers get their own copy
+func loadTree(treeHash string) (*Tree, error) {
+	key := objectCacheKey(".", treeHash)
+	if cached, ok := treeCache.get(key); ok {
+		return cached.clone(), nil
 	}
 
-	return t, nil
+	tree, err := readTree(".", treeHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree '%s': %w", treeHash, err)
+	}
+	treeCache.add(key, tree, treeCacheSize(tree))
+
+	return tree.clone(), nil
+}
+
+// treeCacheSize estimates the memory held by <tree>
+func treeCacheSize(tree *Tree) int64 {
+	size := int64(len(tree.Hash))
+	for _, e := range tree.Entries {
+		size += int64(len(e.Type) + len(e.Name) + len(e.Hash))
+	}
+	return size
+}
+
+// clone returns a deep copy of the tree
+func (t *Tree) clone() *Tree {
+	return &Tree{
+		Hash:    t.Hash,
+		Entries: append([]TreeEntry{}, t.Entries...),
+	}
 }
 
 // buildWorkingDirectoryTree returns a Tree object representing the current working directory
@@ -176,13 +239,11 @@ func buildWorkingDirectoryTree(basePath string) (*Tree, error) {
 				Hash: subTree.Hash,
 			})
 		} else {
-			content, err := os.ReadFile(path)
+			hash, err := hashBlobFile(path)
 			if err != nil {
 				return err
 			}
 
-			hash := ComputeHash(content)
-
 			entries = append(entries, TreeEntry{
 				Type: "blob",
 				Name: relPath,
@@ -206,7 +267,7 @@ func buildWorkingDirectoryTree(basePath string) (*Tree, error) {
 		fmt.Fprintf(&buf, "%s %s\n", e.Type, e.Name)
 	}
 
-	treeHash := ComputeHash(buf.Bytes())
+	treeHash := hashObject(treeObject, buf.Bytes())
 
 	return &Tree{
 		Hash:    treeHash,
@@ -215,29 +276,17 @@ func buildWorkingDirectoryTree(basePath string) (*Tree, error) {
 }
 
 func ExtractTree(repoPath, treeHash, dstPath string) error {
-	treePath := filepath.Join(
-		repoPath, config.REPO_DIR, config.OBJECTS_DIR, treeHash)
-	treeContent, err := os.ReadFile(treePath)
+	tree, err := readTree(repoPath, treeHash)
 	if err != nil {
 		return fmt.Errorf("failed to read tree object %s: %w", treeHash, err)
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(treeContent)), "\n")
-	// line: <type> <name> <hash>
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.SplitN(line, " ", 3)
-		if len(parts) != 3 {
-			return fmt.Errorf("malformed tree entry line: %s", line)
-		}
-
-		typ, name, hash := parts[0], parts[1], parts[2]
+	for _, entry := range tree.Entries {
+		typ, name, hash, mode := entry.Type, entry.Name, entry.Hash, entry.Mode
 		entryPath := filepath.Join(dstPath, name)
 
 		switch typ {
-		case "tree":
+		case treeObject:
 			if err := os.MkdirAll(entryPath, 0755); err != nil {
 				return fmt.Errorf(
 					"failed to create directory %s: %w", entryPath, err)
@@ -245,15 +294,8 @@ func ExtractTree(repoPath, treeHash, dstPath string) error {
 			if err := ExtractTree(repoPath, hash, entryPath); err != nil {
 				return err
 			}
-		case "blob":
-			blobPath := filepath.Join(
-				repoPath, config.REPO_DIR, config.OBJECTS_DIR, hash)
-			content, err := os.ReadFile(blobPath)
-			if err != nil {
-				return fmt.Errorf("failed to read blob %s: %w", hash, err)
-			}
-
-			if err := os.WriteFile(entryPath, content, 0644); err != nil {
+		case blobObject:
+			if err := extractBlobFile(repoPath, hash, entryPath, mode); err != nil {
 				return fmt.Errorf("failed to write file %s: %w", entryPath, err)
 			}
 		default:
@@ -2,18 +2,86 @@ package internal
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"hash"
 	"io"
+	"jit/config"
 	"os"
 	"path/filepath"
 )
 
+// supported object hash algorithms
+const (
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+)
+
+// IsHashAlgorithm reports whether <name> is a supported hash algorithm
+func IsHashAlgorithm(name string) bool {
+	return name == SHA1 || name == SHA256
+}
+
+// SetHashAlgorithm records the hash algorithm of the repository at
+// <repoPath> in its config, it can only be chosen when creating a repository
+func SetHashAlgorithm(repoPath, algorithm string) error {
+	if !IsHashAlgorithm(algorithm) {
+		return fmt.Errorf("unsupported hash algorithm '%s'", algorithm)
+	}
+	return setRepoSetting(repoPath, config.HASH_ALGORITHM_KEY, algorithm)
+}
+
+// hashAlgorithm returns the hash algorithm of the repository at <repoPath>
+// repositories created before it was configurable use SHA-1
+func hashAlgorithm(repoPath string) string {
+	if algorithm := repoSetting(repoPath, config.HASH_ALGORITHM_KEY); IsHashAlgorithm(algorithm) {
+		return algorithm
+	}
+	return SHA1
+}
+
+// ComputeHash hashes <data> with the hash algorithm of the current repository
 func ComputeHash(data []byte) string {
-	h := sha1.New()
+	return computeRepoHash(".", data)
+}
+
+// computeRepoHash hashes <data> with the hash algorithm of the
+// repository at <repoPath>
+func computeRepoHash(repoPath string, data []byte) string {
+	h := newHasher(repoPath)
 	h.Write(data)
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// newHasher returns the hash function object ids of the repository
+// at <repoPath> are computed with
+func newHasher(repoPath string) hash.Hash {
+	if hashAlgorithm(repoPath) == SHA256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// isValidHash reports whether <s> looks like an object id of the
+// current repository, 40 hex characters for SHA-1 and 64 for SHA-256
+func isValidHash(s string) bool {
+	return len(s) == 2*newHasher(".").Size() && isHexString(s)
+}
+
+// isHexString reports whether <s> is a non-empty lowercase hex string
+func isHexString(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 // CopyDir copies <src> directory to <dst> directory
 func CopyDir(src, dst string) error {
 	entries, err := os.ReadDir(src)
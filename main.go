@@ -3,11 +3,25 @@ package main
 import (
 	"fmt"
 	"jit/command"
+	"jit/internal"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	if err := command.Execute(); err != nil {
+	// don't leave lock files behind when interrupted
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		internal.ReleaseLocks()
+		os.Exit(130)
+	}()
+
+	err := command.Execute()
+	internal.ReleaseLocks()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}